@@ -1,7 +1,10 @@
 package http
 
-import "context"
+import (
+	"context"
+	"kevinjuniawan/bookcabin/internal"
+)
 
 type ICache interface {
-	IsRequestLimiterExceeded(ctx context.Context, URI string) bool
+	AllowRequest(ctx context.Context, clientID string, tier internal.RateLimitTier) internal.RateLimitResult
 }
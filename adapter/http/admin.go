@@ -0,0 +1,108 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"kevinjuniawan/bookcabin/internal"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *Handler) GetTenant(w http.ResponseWriter, r *http.Request) {
+	tenant, err := h.flightService.GetTenant(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, internal.ErrTenantNotFound) {
+		WriteJSON(w, 404, NewTenantResponse(err.Error(), nil))
+		return
+	}
+	if err != nil {
+		WriteJSON(w, 500, NewTenantResponse(err.Error(), nil))
+		return
+	}
+
+	WriteJSON(w, 200, NewTenantResponse("Tenant retrieved successfully", &tenant))
+}
+
+func (h *Handler) SetTenant(w http.ResponseWriter, r *http.Request) {
+	var tenant internal.Tenant
+	err := json.NewDecoder(r.Body).Decode(&tenant)
+	if err != nil {
+		WriteJSON(w, 400, NewTenantResponse(err.Error(), nil))
+		return
+	}
+	tenant.ID = mux.Vars(r)["id"]
+
+	err = tenant.Validate()
+	if err != nil {
+		WriteJSON(w, 400, NewTenantResponse(err.Error(), nil))
+		return
+	}
+
+	err = h.flightService.SetTenant(r.Context(), tenant)
+	if errors.Is(err, internal.ErrTenantManagedByConfig) {
+		WriteJSON(w, 409, NewTenantResponse(err.Error(), nil))
+		return
+	}
+	if err != nil {
+		WriteJSON(w, 500, NewTenantResponse(err.Error(), nil))
+		return
+	}
+
+	WriteJSON(w, 200, NewTenantResponse("Tenant saved successfully", &tenant))
+}
+
+func (h *Handler) IssueAPIKey(w http.ResponseWriter, r *http.Request) {
+	key, err := h.flightService.IssueAPIKey(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, internal.ErrTenantNotFound) {
+		WriteJSON(w, 404, NewAPIKeyResponse(err.Error(), nil))
+		return
+	}
+	if err != nil {
+		WriteJSON(w, 500, NewAPIKeyResponse(err.Error(), nil))
+		return
+	}
+
+	WriteJSON(w, 201, NewAPIKeyResponse("API key issued successfully, the key is only shown once", &key))
+}
+
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	err := h.flightService.RevokeAPIKey(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, internal.ErrAPIKeyNotFound) {
+		WriteJSON(w, 404, NewAPIKeyResponse(err.Error(), nil))
+		return
+	}
+	if err != nil {
+		WriteJSON(w, 500, NewAPIKeyResponse(err.Error(), nil))
+		return
+	}
+
+	WriteJSON(w, 200, NewAPIKeyResponse("API key revoked successfully", nil))
+}
+
+func (h *Handler) GetPriceRules(w http.ResponseWriter, r *http.Request) {
+	rules := h.flightService.GetPriceRules()
+	WriteJSON(w, 200, NewPriceRulesResponse("Price rules retrieved successfully", &rules))
+}
+
+func (h *Handler) SetPriceRules(w http.ResponseWriter, r *http.Request) {
+	rules := []internal.PriceRule{}
+	err := json.NewDecoder(r.Body).Decode(&rules)
+	if err != nil {
+		WriteJSON(w, 400, NewPriceRulesResponse(err.Error(), nil))
+		return
+	}
+
+	err = internal.ValidatePriceRules(rules)
+	if err != nil {
+		WriteJSON(w, 400, NewPriceRulesResponse(err.Error(), nil))
+		return
+	}
+
+	saved, err := h.flightService.SetPriceRules(r.Context(), rules)
+	if err != nil {
+		WriteJSON(w, 500, NewPriceRulesResponse(err.Error(), nil))
+		return
+	}
+
+	WriteJSON(w, 200, NewPriceRulesResponse("Price rules saved successfully", &saved))
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"errors"
+	"kevinjuniawan/bookcabin/internal"
+	"net/http"
+)
+
+// authenticate resolve the tenant of X-API-Key and keep it in the request context
+func (h *Handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.authEnabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tenant, err := h.flightService.Authenticate(r.Context(), r.Header.Get("X-API-Key"))
+		if errors.Is(err, internal.ErrInvalidAPIKey) {
+			WriteJSON(w, 401, NewTenantResponse(err.Error(), nil))
+			return
+		}
+		if errors.Is(err, internal.ErrAuthUnavailable) {
+			WriteJSON(w, 503, NewTenantResponse(err.Error(), nil))
+			return
+		}
+		if err != nil {
+			WriteJSON(w, 500, NewTenantResponse(err.Error(), nil))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(internal.WithTenant(r.Context(), tenant)))
+	})
+}
+
+// authorizeAdmin accept only the X-Admin-Key matching ADMIN_API_KEY_HASH, tenant keys are rejected
+func (h *Handler) authorizeAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.authEnabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !h.flightService.IsAdminKey(r.Header.Get("X-Admin-Key")) {
+			WriteJSON(w, 401, NewTenantResponse("admin key is invalid", nil))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// NewTrustedProxies parse TRUSTED_PROXIES, a single IP is a /32 or /128 network
+func NewTrustedProxies(value string) []*net.IPNet {
+	proxies := []*net.IPNet{}
+	for _, proxy := range strings.Split(value, ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			log.Printf("fail to parse trusted proxy config, Err : %v\n", err)
+			continue
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies
+}
+
+func (h *Handler) isTrustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range h.trustedProxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP is the connection address, X-Forwarded-For is only read when the connection come from a trusted proxy
+// the header is walked from the right, the first hop not added by a trusted proxy is the client
+func (h *Handler) clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !h.isTrustedProxy(ip) {
+		return ip
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if net.ParseIP(hop) == nil {
+			return ip
+		}
+		ip = hop
+		if !h.isTrustedProxy(hop) {
+			return ip
+		}
+	}
+	return ip
+}
@@ -0,0 +1,36 @@
+package http
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	h := &Handler{trustedProxies: NewTrustedProxies("10.0.0.0/8, 192.168.1.1")}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{name: "no proxy", remoteAddr: "203.0.113.7:5000", want: "203.0.113.7"},
+		{name: "untrusted connection ignore header", remoteAddr: "203.0.113.7:5000", forwarded: []string{"1.1.1.1"}, want: "203.0.113.7"},
+		{name: "trusted proxy", remoteAddr: "10.0.0.2:5000", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "spoofed left hop", remoteAddr: "10.0.0.2:5000", forwarded: []string{"1.1.1.1, 198.51.100.1"}, want: "198.51.100.1"},
+		{name: "chain of trusted proxies", remoteAddr: "10.0.0.2:5000", forwarded: []string{"1.1.1.1, 198.51.100.1, 192.168.1.1", "10.0.0.3"}, want: "198.51.100.1"},
+		{name: "invalid hop", remoteAddr: "10.0.0.2:5000", forwarded: []string{"198.51.100.1, unknown"}, want: "10.0.0.2"},
+		{name: "trusted proxy without header", remoteAddr: "10.0.0.2:5000", want: "10.0.0.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, forwarded := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", forwarded)
+			}
+			if got := h.clientIP(r); got != tt.want {
+				t.Errorf("clientIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
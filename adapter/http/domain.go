@@ -19,8 +19,11 @@ type SearchCriteriaResponse struct {
 	DepartureDate string                       `json:"departure_date"`
 	Passenger     int16                        `json:"passengers"`
 	CabinClass    string                       `json:"cabin_class"`
+	CabinOrBetter bool                         `json:"cabin_or_better"`
 	SortType      internal.SortType            `json:"sort_type"`
+	Sort          []internal.SortKey           `json:"sort,omitempty"`
 	Filter        *internal.FilterFlightParams `json:"filter,omitempty"`
+	ProfileID     string                       `json:"profile_id,omitempty"`
 }
 
 type MetadataResponse struct {
@@ -30,6 +33,8 @@ type MetadataResponse struct {
 	ProvidersFailed    int16 `json:"providers_failed"`
 	SearchTimeMs       int32 `json:"search_time_ms"`
 	CacheHit           bool  `json:"cache_hit"`
+	Coalesced          bool  `json:"coalesced"`
+	CacheBypassed      bool  `json:"cache_bypassed"` // cache is unavailable, flights come from providers
 }
 
 func NewResponse(message string, data internal.SearchResponse, params internal.GetFlightsParams) Response {
@@ -39,9 +44,12 @@ func NewResponse(message string, data internal.SearchResponse, params internal.G
 			Destination:   params.Destination,
 			DepartureDate: params.DepartureDate,
 			CabinClass:    params.CabinClass,
+			CabinOrBetter: params.CabinOrBetter,
 			Passenger:     params.Passenger,
 			SortType:      params.SortType,
+			Sort:          params.Sort,
 			Filter:        params.Filter,
+			ProfileID:     params.ProfileID,
 		},
 		Metadata: MetadataResponse{
 			TotalResults:       int32(len(data.Flights)),
@@ -50,6 +58,8 @@ func NewResponse(message string, data internal.SearchResponse, params internal.G
 			ProvidersFailed:    data.Metadata.ProviderCount - data.Metadata.SucceededProvider,
 			SearchTimeMs:       data.Metadata.SearchTimeMs,
 			CacheHit:           data.Metadata.IsCache,
+			Coalesced:          data.Metadata.IsCoalesced,
+			CacheBypassed:      data.Metadata.IsCacheBypassed,
 		},
 		Message: message,
 		Flights: data.Flights,
@@ -57,6 +67,114 @@ func NewResponse(message string, data internal.SearchResponse, params internal.G
 
 }
 
+type ProfileResponse struct {
+	Message string                     `json:"message"`
+	Profile *internal.TravellerProfile `json:"profile,omitempty"`
+}
+
+func NewProfileResponse(message string, profile *internal.TravellerProfile) ProfileResponse {
+	return ProfileResponse{
+		Message: message,
+		Profile: profile,
+	}
+}
+
+type AnalyticsResponse struct {
+	Message   string                    `json:"message"`
+	Analytics *internal.AnalyticsReport `json:"analytics,omitempty"`
+}
+
+func NewAnalyticsResponse(message string, report *internal.AnalyticsReport) AnalyticsResponse {
+	return AnalyticsResponse{
+		Message:   message,
+		Analytics: report,
+	}
+}
+
+type WarmingRunResponse struct {
+	Message string               `json:"message"`
+	Run     *internal.WarmingRun `json:"run,omitempty"`
+}
+
+func NewWarmingRunResponse(message string, run *internal.WarmingRun) WarmingRunResponse {
+	return WarmingRunResponse{
+		Message: message,
+		Run:     run,
+	}
+}
+
+type FlightResponse struct {
+	Message string           `json:"message"`
+	Flight  *internal.Flight `json:"flight,omitempty"`
+}
+
+func NewFlightResponse(message string, flight *internal.Flight) FlightResponse {
+	return FlightResponse{
+		Message: message,
+		Flight:  flight,
+	}
+}
+
+type PriceCheckResponse struct {
+	Message string                     `json:"message"`
+	Result  *internal.PriceCheckResult `json:"result,omitempty"`
+}
+
+func NewPriceCheckResponse(message string, result *internal.PriceCheckResult) PriceCheckResponse {
+	return PriceCheckResponse{
+		Message: message,
+		Result:  result,
+	}
+}
+
+type BookingResponse struct {
+	Message string            `json:"message"`
+	Booking *internal.Booking `json:"booking,omitempty"`
+}
+
+func NewBookingResponse(message string, booking *internal.Booking) BookingResponse {
+	return BookingResponse{
+		Message: message,
+		Booking: booking,
+	}
+}
+
+type TenantResponse struct {
+	Message string           `json:"message"`
+	Tenant  *internal.Tenant `json:"tenant,omitempty"`
+}
+
+func NewTenantResponse(message string, tenant *internal.Tenant) TenantResponse {
+	return TenantResponse{
+		Message: message,
+		Tenant:  tenant,
+	}
+}
+
+type APIKeyResponse struct {
+	Message string                 `json:"message"`
+	APIKey  *internal.IssuedAPIKey `json:"api_key,omitempty"`
+}
+
+func NewAPIKeyResponse(message string, key *internal.IssuedAPIKey) APIKeyResponse {
+	return APIKeyResponse{
+		Message: message,
+		APIKey:  key,
+	}
+}
+
+type PriceRulesResponse struct {
+	Message    string                 `json:"message"`
+	PriceRules *internal.PriceRuleSet `json:"price_rules,omitempty"`
+}
+
+func NewPriceRulesResponse(message string, rules *internal.PriceRuleSet) PriceRulesResponse {
+	return PriceRulesResponse{
+		Message:    message,
+		PriceRules: rules,
+	}
+}
+
 func WriteJSON(w http.ResponseWriter, status int, data any) {
 	w.WriteHeader(status)
 	w.Header().Set("Content-Type", "application/json")
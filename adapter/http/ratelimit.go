@@ -0,0 +1,42 @@
+package http
+
+import (
+	"kevinjuniawan/bookcabin/internal"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// rateLimitClient count requests per authenticated tenant, otherwise per client IP
+// an unverified X-API-Key is not used since a new key per request would get a new window
+func (h *Handler) rateLimitClient(r *http.Request) string {
+	if tenant, ok := internal.TenantFromContext(r.Context()); ok {
+		return "tenant:" + tenant.ID
+	}
+	return "ip:" + h.clientIP(r)
+}
+
+// rateLimitTier fallback to the default tier when the tenant tier is not in RATE_LIMIT_TIERS
+func (h *Handler) rateLimitTier(r *http.Request) internal.RateLimitTier {
+	if tenant, ok := internal.TenantFromContext(r.Context()); ok {
+		if tier, exist := h.rateLimitTiers[tenant.RateLimitTier]; exist {
+			return tier
+		}
+	}
+	return h.rateLimitTiers[internal.DefaultRateLimitTier]
+}
+
+// allowRequest write the X-RateLimit headers, and Retry-After in seconds when the request is rejected
+func (h *Handler) allowRequest(w http.ResponseWriter, r *http.Request) bool {
+	tier := h.rateLimitTier(r)
+	if tier.Limit <= 0 {
+		return true
+	}
+	result := h.cacheService.AllowRequest(r.Context(), h.rateLimitClient(r), tier)
+	w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(result.Limit, 10))
+	w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+	if !result.Allowed {
+		w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(result.RetryAfter.Seconds())), 10))
+	}
+	return result.Allowed
+}
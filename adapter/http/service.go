@@ -2,35 +2,90 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"kevinjuniawan/bookcabin/internal"
+	"net"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 type Handler struct {
-	flightService internal.InternalService
-	cacheService  ICache
+	flightService  internal.InternalService
+	cacheService   ICache
+	rateLimitTiers map[string]internal.RateLimitTier
+	authEnabled    bool
+	trustedProxies []*net.IPNet
 }
 
 type Params struct {
-	FlightService *internal.InternalService
-	CacheService  ICache
+	FlightService  *internal.InternalService
+	CacheService   ICache
+	RateLimitTiers map[string]internal.RateLimitTier
+	AuthEnabled    bool
+	TrustedProxies []*net.IPNet
 }
 
 func NewHandler(p Params) *Handler {
 	return &Handler{
-		flightService: *p.FlightService,
-		cacheService:  p.CacheService,
+		flightService:  *p.FlightService,
+		cacheService:   p.CacheService,
+		rateLimitTiers: p.RateLimitTiers,
+		authEnabled:    p.AuthEnabled,
+		trustedProxies: p.TrustedProxies,
 	}
 }
 
 func (h *Handler) InitRouter() http.Handler {
 	mux := mux.NewRouter()
-	mux.HandleFunc("/flights/search", h.SearchFlights).Methods("POST")
+	mux.HandleFunc("/health", h.Health).Methods("GET")
+
+	admin := mux.NewRoute().Subrouter()
+	admin.Use(h.authorizeAdmin)
+	admin.HandleFunc("/analytics", h.GetAnalytics).Methods("GET")
+	admin.HandleFunc("/warming/runs/last", h.GetLastWarmingRun).Methods("GET")
+	admin.HandleFunc("/admin/tenants/{id}", h.GetTenant).Methods("GET")
+	admin.HandleFunc("/admin/tenants/{id}", h.SetTenant).Methods("PUT")
+	admin.HandleFunc("/admin/tenants/{id}/keys", h.IssueAPIKey).Methods("POST")
+	admin.HandleFunc("/admin/keys/{id}", h.RevokeAPIKey).Methods("DELETE")
+	admin.HandleFunc("/admin/price-rules", h.GetPriceRules).Methods("GET")
+	admin.HandleFunc("/admin/price-rules", h.SetPriceRules).Methods("PUT")
+
+	tenant := mux.NewRoute().Subrouter()
+	tenant.Use(h.authenticate)
+	tenant.HandleFunc("/flights/search", h.SearchFlights).Methods("POST")
+	tenant.HandleFunc("/flights/{id}", h.GetFlightDetail).Methods("GET")
+	tenant.HandleFunc("/flights/{id}/price", h.RevalidateFlightPrice).Methods("POST")
+	tenant.HandleFunc("/bookings", h.CreateBooking).Methods("POST")
+	tenant.HandleFunc("/bookings/{locator}", h.GetBooking).Methods("GET")
+	tenant.HandleFunc("/bookings/{locator}", h.CancelBooking).Methods("DELETE")
+	tenant.HandleFunc("/profiles/{id}", h.GetTravellerProfile).Methods("GET")
+	tenant.HandleFunc("/profiles/{id}", h.SetTravellerProfile).Methods("PUT")
 	return mux
 }
 
+// Health is 200 also when degraded since searches are still answered by providers
+func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, 200, h.flightService.Health(r.Context()))
+}
+
+func (h *Handler) GetLastWarmingRun(w http.ResponseWriter, r *http.Request) {
+	run, err := h.flightService.GetLastWarmingRun(r.Context())
+	if errors.Is(err, internal.ErrWarmingRunNotFound) {
+		WriteJSON(w, 404, NewWarmingRunResponse(err.Error(), nil))
+		return
+	}
+	if err != nil {
+		WriteJSON(w, 500, NewWarmingRunResponse(err.Error(), nil))
+		return
+	}
+
+	WriteJSON(w, 200, NewWarmingRunResponse("Warming run retrieved successfully", &run))
+}
+
 func (h *Handler) SearchFlights(w http.ResponseWriter, r *http.Request) {
 	var params internal.GetFlightsParams
 	err := json.NewDecoder(r.Body).Decode(&params)
@@ -39,22 +94,198 @@ func (h *Handler) SearchFlights(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if h.cacheService.IsRequestLimiterExceeded(r.Context(), r.URL.String()) {
-		WriteJSON(w, 429, NewResponse("Too many requests", internal.SearchResponse{}, params))
-		return
-	}
-
 	err = params.Validate()
 	if err != nil {
 		WriteJSON(w, 400, NewResponse(err.Error(), internal.SearchResponse{}, params))
 		return
 	}
 
+	if !h.allowRequest(w, r) {
+		WriteJSON(w, 429, NewResponse("Too many requests", internal.SearchResponse{}, params))
+		return
+	}
+
 	flights, err := h.flightService.GetFlights(r.Context(), params)
+	if errors.Is(err, internal.ErrUnknownScoringProfile) || errors.Is(err, internal.ErrTravellerProfileNotFound) {
+		WriteJSON(w, 400, NewResponse(err.Error(), internal.SearchResponse{}, params))
+		return
+	}
 	if err != nil {
 		WriteJSON(w, 500, NewResponse(err.Error(), internal.SearchResponse{}, params))
 		return
 	}
 
+	h.flightService.RecordSearch(internal.SearchEvent{
+		Origin:        params.Origin,
+		Destination:   params.Destination,
+		DepartureDate: params.DepartureDate,
+		ClientID:      h.clientIP(r),
+		IsCache:       flights.Metadata.IsCache,
+		ResultCount:   len(flights.Flights),
+		SearchedAt:    time.Now(),
+	})
 	WriteJSON(w, 200, NewResponse("Flights retrieved successfully", flights, params))
 }
+
+func (h *Handler) GetAnalytics(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := internal.GetAnalyticsParams{Window: query.Get("window")}
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		params.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			WriteJSON(w, 400, NewAnalyticsResponse("limit must be a number", nil))
+			return
+		}
+	}
+
+	report, err := h.flightService.GetAnalytics(r.Context(), params)
+	if errors.Is(err, internal.ErrInvalidAnalyticsWindow) {
+		WriteJSON(w, 400, NewAnalyticsResponse(err.Error(), nil))
+		return
+	}
+	if err != nil {
+		WriteJSON(w, 500, NewAnalyticsResponse(err.Error(), nil))
+		return
+	}
+
+	WriteJSON(w, 200, NewAnalyticsResponse("Analytics retrieved successfully", &report))
+}
+
+func (h *Handler) GetTravellerProfile(w http.ResponseWriter, r *http.Request) {
+	profile, err := h.flightService.GetTravellerProfile(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, internal.ErrTravellerProfileNotFound) {
+		WriteJSON(w, 404, NewProfileResponse(err.Error(), nil))
+		return
+	}
+	if err != nil {
+		WriteJSON(w, 500, NewProfileResponse(err.Error(), nil))
+		return
+	}
+
+	WriteJSON(w, 200, NewProfileResponse("Profile retrieved successfully", &profile))
+}
+
+func (h *Handler) SetTravellerProfile(w http.ResponseWriter, r *http.Request) {
+	var profile internal.TravellerProfile
+	err := json.NewDecoder(r.Body).Decode(&profile)
+	if err != nil {
+		WriteJSON(w, 400, NewProfileResponse(err.Error(), nil))
+		return
+	}
+	profile.ID = mux.Vars(r)["id"]
+
+	err = profile.Validate()
+	if err != nil {
+		WriteJSON(w, 400, NewProfileResponse(err.Error(), nil))
+		return
+	}
+
+	err = h.flightService.SetTravellerProfile(r.Context(), profile)
+	if err != nil {
+		WriteJSON(w, 500, NewProfileResponse(err.Error(), nil))
+		return
+	}
+
+	WriteJSON(w, 200, NewProfileResponse("Profile saved successfully", &profile))
+}
+
+func (h *Handler) RevalidateFlightPrice(w http.ResponseWriter, r *http.Request) {
+	var params internal.PriceCheckParams
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil && !errors.Is(err, io.EOF) {
+		WriteJSON(w, 400, NewPriceCheckResponse(err.Error(), nil))
+		return
+	}
+
+	result, err := h.flightService.RevalidateFlightPrice(r.Context(), mux.Vars(r)["id"], params)
+	if errors.Is(err, internal.ErrFlightNotFound) || errors.Is(err, internal.ErrFareNotFound) {
+		WriteJSON(w, 404, NewPriceCheckResponse(err.Error(), nil))
+		return
+	}
+	if err != nil {
+		WriteJSON(w, 500, NewPriceCheckResponse(err.Error(), nil))
+		return
+	}
+
+	WriteJSON(w, 200, NewPriceCheckResponse("Price revalidated successfully", &result))
+}
+
+func (h *Handler) CreateBooking(w http.ResponseWriter, r *http.Request) {
+	var params internal.CreateBookingParams
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		WriteJSON(w, 400, NewBookingResponse(err.Error(), nil))
+		return
+	}
+
+	err = params.Validate()
+	if err != nil {
+		WriteJSON(w, 400, NewBookingResponse(err.Error(), nil))
+		return
+	}
+
+	booking, err := h.flightService.CreateBooking(r.Context(), params)
+	if errors.Is(err, internal.ErrFlightNotFound) || errors.Is(err, internal.ErrFareNotFound) {
+		WriteJSON(w, 404, NewBookingResponse(err.Error(), nil))
+		return
+	}
+	if errors.Is(err, internal.ErrSeatsUnavailable) {
+		WriteJSON(w, 409, NewBookingResponse(err.Error(), nil))
+		return
+	}
+	if err != nil {
+		WriteJSON(w, 500, NewBookingResponse(err.Error(), nil))
+		return
+	}
+
+	WriteJSON(w, 201, NewBookingResponse("Booking held successfully", &booking))
+}
+
+func (h *Handler) GetBooking(w http.ResponseWriter, r *http.Request) {
+	booking, err := h.flightService.GetBooking(r.Context(), mux.Vars(r)["locator"])
+	if errors.Is(err, internal.ErrBookingNotFound) {
+		WriteJSON(w, 404, NewBookingResponse(err.Error(), nil))
+		return
+	}
+	if err != nil {
+		WriteJSON(w, 500, NewBookingResponse(err.Error(), nil))
+		return
+	}
+
+	WriteJSON(w, 200, NewBookingResponse("Booking retrieved successfully", &booking))
+}
+
+func (h *Handler) CancelBooking(w http.ResponseWriter, r *http.Request) {
+	booking, err := h.flightService.CancelBooking(r.Context(), mux.Vars(r)["locator"])
+	if errors.Is(err, internal.ErrBookingNotFound) {
+		WriteJSON(w, 404, NewBookingResponse(err.Error(), nil))
+		return
+	}
+	if err != nil {
+		WriteJSON(w, 500, NewBookingResponse(err.Error(), nil))
+		return
+	}
+
+	WriteJSON(w, 200, NewBookingResponse("Booking cancelled successfully", &booking))
+}
+
+func (h *Handler) GetFlightDetail(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	flight, err := h.flightService.GetFlightDetail(r.Context(), mux.Vars(r)["id"], internal.GetFlightDetailParams{
+		Origin:        query.Get("origin"),
+		Destination:   query.Get("destination"),
+		DepartureDate: query.Get("departure_date"),
+		CabinClass:    query.Get("cabin_class"),
+	})
+	if errors.Is(err, internal.ErrFlightNotFound) {
+		WriteJSON(w, 404, NewFlightResponse(err.Error(), nil))
+		return
+	}
+	if err != nil {
+		WriteJSON(w, 500, NewFlightResponse(err.Error(), nil))
+		return
+	}
+
+	WriteJSON(w, 200, NewFlightResponse("Flight retrieved successfully", &flight))
+}
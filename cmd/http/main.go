@@ -15,16 +15,40 @@ import (
 func main() {
 	cfg, _ := config.Load()
 	log.Printf("Initializing %s...\n", cfg.AppName)
+	if cfg.AuthEnabled && cfg.Tenants == "" && cfg.AdminAPIKeyHash == "" {
+		log.Fatalf("AUTH_ENABLED needs TENANTS or ADMIN_API_KEY_HASH, otherwise every request is rejected\n")
+	}
 	ctx := context.Background()
 	api := api.NewFetcherService(api.FetcherServiceParams{Cfg: *cfg})
-	redis := cache.NewCacheService(ctx, cache.ServiceParams{
-		Address:  cfg.RedisAddr,
-		Password: cfg.RedisPassword,
-		DB:       cfg.RedisDB,
-		Cfg:      cfg,
-	})
-	internal := internal.NewInternalService(internal.InternalServiceParams{FetcherService: api, CacheService: redis})
-	handler := httpAdapter.NewHandler(httpAdapter.Params{FlightService: internal, CacheService: redis})
+	var cacheService interface {
+		internal.ICache
+		internal.IAnalytics
+		httpAdapter.ICache
+	}
+	switch cfg.CacheBackend {
+	case "memory":
+		log.Printf("Using in-memory cache, data is not shared between instances\n")
+		cacheService = cache.NewMemoryCacheService(cfg)
+	default:
+		redis := cache.NewCacheService(ctx, cache.ServiceParams{
+			Address:  cfg.RedisAddr,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+			Cfg:      cfg,
+		})
+		cacheService = redis
+		if cfg.LocalCacheEnabled {
+			cacheService = cache.NewTwoTierCacheService(ctx, redis)
+		}
+	}
+	rateLimitTiers := internal.NewRateLimitTiers(*cfg)
+	internal := internal.NewInternalService(internal.InternalServiceParams{FetcherService: api, CacheService: cacheService, BookingProvider: api, Analytics: cacheService, Cfg: *cfg})
+	internal.ReloadPriceRules(ctx)
+	go internal.StartPriceRulesReload(ctx)
+	if cfg.WarmingEnabled {
+		go internal.StartCacheWarming(ctx)
+	}
+	handler := httpAdapter.NewHandler(httpAdapter.Params{FlightService: internal, CacheService: cacheService, RateLimitTiers: rateLimitTiers, AuthEnabled: cfg.AuthEnabled, TrustedProxies: httpAdapter.NewTrustedProxies(cfg.TrustedProxies)})
 
 	log.Printf("Starting listening for request on port %d \n", cfg.Port)
 	http.ListenAndServe(":"+strconv.Itoa(cfg.Port), handler.InitRouter())
@@ -14,16 +14,56 @@ type Config struct {
 	AppName     string `env:"APP_NAME" envDefault:"search-service"`
 	ServiceType string `env:"SERVICE_TYPE"` // http, grpc. event
 
+	TrustedProxies string `env:"TRUSTED_PROXIES"` // comma separated IPs or CIDRs of proxies allowed to set X-Forwarded-For
+
 	//Cache
-	RedisAddr         string        `env:"REDIS_ADDR" envDefault:"localhost:6379"`
-	RedisPassword     string        `env:"REDIS_PASSWORD" envDefault:""`
-	RedisDB           int           `env:"REDIS_DB" envDefault:"0"`
-	RequestLimiterTTL time.Duration `env:"REQUEST_LIMITER_TTL" envDefault:"10s"`
-	RequestLimiterMax int64         `env:"REQUEST_LIMITER_MAX"`
+	CacheBackend         string        `env:"CACHE_BACKEND" envDefault:"redis"` // redis, memory
+	RedisAddr            string        `env:"REDIS_ADDR" envDefault:"localhost:6379"`
+	RedisPassword        string        `env:"REDIS_PASSWORD" envDefault:""`
+	RedisDB              int           `env:"REDIS_DB" envDefault:"0"`
+	RequestLimiterTTL    time.Duration `env:"REQUEST_LIMITER_TTL" envDefault:"10s"` // sliding window of the default rate limit tier
+	RequestLimiterMax    int64         `env:"REQUEST_LIMITER_MAX"`                  // requests per client of the default tier, 0 is unlimited
+	RateLimitTiers       string        `env:"RATE_LIMIT_TIERS"`                     // comma separated NAME:LIMIT/WINDOW, e.g. partner:600/1m
+	CacheTTL             time.Duration `env:"CACHE_TTL" envDefault:"10m"`
+	CacheStaleTTL        time.Duration `env:"CACHE_STALE_TTL" envDefault:"5m"`          // stale flights are served while a replica rebuild the route
+	RebuildLockTTL       time.Duration `env:"REBUILD_LOCK_TTL" envDefault:"10s"`        // lock expire when the holder crash
+	RebuildLockWait      time.Duration `env:"REBUILD_LOCK_WAIT" envDefault:"2s"`        // max wait for another replica rebuild
+	CacheMaxMissingRatio float64       `env:"CACHE_MAX_MISSING_RATIO" envDefault:"0.2"` // route is refetched when more flights are missing
+	CacheBypassTime      time.Duration `env:"CACHE_BYPASS_TIME" envDefault:"10s"`       // cache is skipped after a failure for this long
+
+	//Local cache
+	LocalCacheEnabled    bool          `env:"LOCAL_CACHE_ENABLED" envDefault:"false"`
+	LocalCacheTTL        time.Duration `env:"LOCAL_CACHE_TTL" envDefault:"30s"`
+	LocalCacheMaxEntries int           `env:"LOCAL_CACHE_MAX_ENTRIES" envDefault:"1000"`
+	LocalCacheMaxFlights int           `env:"LOCAL_CACHE_MAX_FLIGHTS" envDefault:"50000"` // total flights across entries
+
+	//Cache warming
+	WarmingEnabled      bool          `env:"WARMING_ENABLED" envDefault:"false"`
+	WarmingRoutes       string        `env:"WARMING_ROUTES"` // comma separated ORIGIN-DESTINATION:DAYS, e.g. CGK-DPS:30
+	WarmingCabinClasses string        `env:"WARMING_CABIN_CLASSES" envDefault:"economy"`
+	WarmingInterval     time.Duration `env:"WARMING_INTERVAL" envDefault:"30m"`
+	WarmingConcurrency  int           `env:"WARMING_CONCURRENCY" envDefault:"4"`
+
+	//Auth
+	AuthEnabled     bool          `env:"AUTH_ENABLED" envDefault:"false"`
+	AdminAPIKeyHash string        `env:"ADMIN_API_KEY_HASH"`                // SHA-256 hex of the admin key, admin api is disabled when empty
+	Tenants         string        `env:"TENANTS"`                           // JSON object of tenant ID to tenant with api_key_hashes, see README
+	APIKeyCacheTTL  time.Duration `env:"API_KEY_CACHE_TTL" envDefault:"5m"` // issued keys verified within this period are accepted while the cache is unavailable
+
+	//Pricing
+	PriceRulesFile           string        `env:"PRICE_RULES_FILE"` // JSON array of price rules, used when no rules are saved in Redis, see README
+	PriceRulesReloadInterval time.Duration `env:"PRICE_RULES_RELOAD_INTERVAL" envDefault:"30s"`
 
 	//API call
 	MaxRetryCount int `env:"MAX_RETRY_COUNT" envDefault:"3"`
 	RetryBackOff  int `env:"RETRY_BACKOFF" envDefault:"200"`
+
+	//Booking
+	BookingHoldTTL time.Duration `env:"BOOKING_HOLD_TTL" envDefault:"15m"`
+
+	//Scoring
+	ScoringProfiles       string `env:"SCORING_PROFILES"` // JSON object of profile name to weights, see README
+	DefaultScoringProfile string `env:"DEFAULT_SCORING_PROFILE" envDefault:"balanced"`
 }
 
 var (
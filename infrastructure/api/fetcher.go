@@ -1,6 +1,8 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"kevinjuniawan/bookcabin/config"
 	mockflight "kevinjuniawan/bookcabin/infrastructure/api/mock_flight"
 	"kevinjuniawan/bookcabin/internal"
@@ -28,43 +30,34 @@ type FetcherServiceParams struct {
 
 func NewFetcherService(params FetcherServiceParams) *FetcherService {
 	return &FetcherService{
-		Cfg: params.Cfg,
+		AirAsiaAPI:   mockflight.NewAirAsia(),
+		GarudaAirAPI: mockflight.NewGarudaAir(),
+		LionAirAPI:   mockflight.NewLionAir(),
+		BatikAirAPI:  mockflight.NewBatikAir(),
+		Cfg:          params.Cfg,
 	}
 }
 
+var providers = []mockflight.AirlineCode{
+	mockflight.AirAsiaAirline,
+	mockflight.GarudaAirline,
+	mockflight.LionAirAirline,
+	mockflight.BatikAirAirline,
+}
+
 func (f *FetcherService) GetFlights(params internal.GetFlightsParams) (internal.FlightDataResponse, error) {
 
 	var wg sync.WaitGroup
-	flightsChan := make(chan FlightCollection, 4)
+	flightsChan := make(chan FlightCollection, len(providers))
 
-	wg.Add(4)
-	go func() {
-		defer wg.Done()
-		res, err := f.AirAsiaAPI.GetFlights(params.Origin, params.Destination, params.DepartureDate)
-		flightsChan <- FlightCollection{Flights: res.Normalize(), err: err}
-	}()
-	go func() {
-		defer wg.Done()
-		res, err := f.GarudaAirAPI.GetFlights(params.Origin, params.Destination, params.DepartureDate)
-		flightsChan <- FlightCollection{Flights: res.Normalize(), err: err}
-	}()
-	go func() {
-		defer wg.Done()
-		for i := 0; i < f.Cfg.MaxRetryCount; i++ {
-			res, err := f.LionAirAPI.GetFlights(params.Origin, params.Destination, params.DepartureDate)
-			if err == nil {
-				flightsChan <- FlightCollection{Flights: res.Normalize(), err: err}
-				break
-			}
-			time.Sleep(time.Duration(f.Cfg.RetryBackOff) * time.Millisecond) // Usually Resti will handle this
-			log.Println("Retrying lion air fetching...")
-		}
-	}()
-	go func() {
-		defer wg.Done()
-		res, err := f.BatikAirAPI.GetFlights(params.Origin, params.Destination, params.DepartureDate)
-		flightsChan <- FlightCollection{Flights: res.Normalize(), err: err}
-	}()
+	wg.Add(len(providers))
+	for _, provider := range providers {
+		go func() {
+			defer wg.Done()
+			flights, err := f.fetchProvider(provider, params)
+			flightsChan <- FlightCollection{Flights: flights, err: err}
+		}()
+	}
 
 	wg.Wait()
 	close(flightsChan)
@@ -75,12 +68,104 @@ func (f *FetcherService) GetFlights(params internal.GetFlightsParams) (internal.
 		if flight.err != nil {
 			failed++
 		}
-		flights = append(flights, flight.Flights...)
+		flights = append(flights, internal.FilterFlightByCabin(flight.Flights, internal.Class(params.CabinClass), params.CabinOrBetter)...)
 	}
 
 	return internal.FlightDataResponse{
-		ProviderCount:  4,
+		ProviderCount:  int16(len(providers)),
 		FailedProvider: int16(failed),
 		Flights:        flights,
 	}, nil
 }
+
+// GetFlight query only the provider owning the flight and return the flight with the given ID
+func (f *FetcherService) GetFlight(provider string, params internal.GetFlightsParams, flightID string) (internal.Flight, error) {
+	for _, code := range providers {
+		if mockflight.GetProviderName(code) != provider {
+			continue
+		}
+		flights, err := f.fetchProvider(code, params)
+		if err != nil {
+			return internal.Flight{}, err
+		}
+		for _, flight := range internal.FilterFlightByCabin(flights, internal.Class(params.CabinClass), params.CabinOrBetter) {
+			if flight.ID == flightID {
+				return flight, nil
+			}
+		}
+		return internal.Flight{}, internal.ErrFlightNotFound
+	}
+	return internal.Flight{}, fmt.Errorf("provider %s is unknown: %w", provider, internal.ErrFlightNotFound)
+}
+
+func (f *FetcherService) fetchProvider(provider mockflight.AirlineCode, params internal.GetFlightsParams) ([]internal.Flight, error) {
+	switch provider {
+	case mockflight.AirAsiaAirline:
+		res, err := f.AirAsiaAPI.GetFlights(params.Origin, params.Destination, params.DepartureDate)
+		return res.Normalize(), err
+	case mockflight.GarudaAirline:
+		res, err := f.GarudaAirAPI.GetFlights(params.Origin, params.Destination, params.DepartureDate)
+		return res.Normalize(), err
+	case mockflight.LionAirAirline:
+		var err error
+		for i := 0; i < f.Cfg.MaxRetryCount; i++ {
+			var res mockflight.LionAirResponse
+			res, err = f.LionAirAPI.GetFlights(params.Origin, params.Destination, params.DepartureDate)
+			if err == nil {
+				return res.Normalize(), nil
+			}
+			time.Sleep(time.Duration(f.Cfg.RetryBackOff) * time.Millisecond) // Usually Resti will handle this
+			log.Println("Retrying lion air fetching...")
+		}
+		return nil, err
+	case mockflight.BatikAirAirline:
+		res, err := f.BatikAirAPI.GetFlights(params.Origin, params.Destination, params.DepartureDate)
+		return res.Normalize(), err
+	}
+	return nil, fmt.Errorf("provider %s is unknown", provider)
+}
+
+func (f *FetcherService) HoldSeats(params internal.HoldSeatsParams) (string, error) {
+	request := mockflight.HoldRequest{
+		Origin:        params.Origin,
+		Destination:   params.Destination,
+		DepartureDate: params.DepartureDate,
+		FlightNumber:  params.FlightNumber,
+		BookingClass:  params.BookingClass,
+		Seats:         params.Seats,
+		TTL:           params.TTL,
+	}
+
+	var holdReference string
+	var err error
+	switch params.Provider {
+	case mockflight.GetProviderName(mockflight.AirAsiaAirline):
+		holdReference, err = f.AirAsiaAPI.Hold(request)
+	case mockflight.GetProviderName(mockflight.GarudaAirline):
+		holdReference, err = f.GarudaAirAPI.Hold(request)
+	case mockflight.GetProviderName(mockflight.LionAirAirline):
+		holdReference, err = f.LionAirAPI.Hold(request)
+	case mockflight.GetProviderName(mockflight.BatikAirAirline):
+		holdReference, err = f.BatikAirAPI.Hold(request)
+	default:
+		return "", fmt.Errorf("provider %s is unknown", params.Provider)
+	}
+	if errors.Is(err, mockflight.ErrSeatsUnavailable) {
+		return "", internal.ErrSeatsUnavailable
+	}
+	return holdReference, err
+}
+
+func (f *FetcherService) ReleaseSeats(provider string, holdReference string) error {
+	switch provider {
+	case mockflight.GetProviderName(mockflight.AirAsiaAirline):
+		return f.AirAsiaAPI.Release(holdReference)
+	case mockflight.GetProviderName(mockflight.GarudaAirline):
+		return f.GarudaAirAPI.Release(holdReference)
+	case mockflight.GetProviderName(mockflight.LionAirAirline):
+		return f.LionAirAPI.Release(holdReference)
+	case mockflight.GetProviderName(mockflight.BatikAirAirline):
+		return f.BatikAirAPI.Release(holdReference)
+	}
+	return fmt.Errorf("provider %s is unknown", provider)
+}
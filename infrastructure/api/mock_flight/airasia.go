@@ -68,45 +68,53 @@ func (r AirAsiaResponse) Normalize() []internal.Flight {
 			continue
 		}
 
-		cabinClass := internal.EconomyClass
-		if flight.CabinClass == "business" {
-			cabinClass = internal.BusinessClass
-		}
-
 		baggageData := GetBaggageInfo(flight.BaggageNote)
 
 		layover := 0
-		if len(flight.Stops) > 0 {
-			for _, stop := range flight.Stops {
-				layover += stop.WaitTimeMinutes
-			}
+		layovers := []internal.Layover{}
+		for _, stop := range flight.Stops {
+			layover += stop.WaitTimeMinutes
+			layovers = append(layovers, internal.Layover{Airport: stop.Airport, TotalMinute: stop.WaitTimeMinutes})
 		}
 
+		airline := internal.Airline{Code: mapAirlineCodeToName[AirAsiaAirline], Name: string(AirAsiaAirline)}
+		departure := internal.Airport{Airport: flight.FromAirport, City: departureCity, Datetime: departureTime.Format(), Timestamp: departureTime.Time.Unix()}
+		arrival := internal.Airport{Airport: flight.ToAirport, City: arrivalCity, Datetime: arrivalTime.Format(), Timestamp: arrivalTime.Time.Unix()}
+		flightID := flight.FlightCode + "_" + mapAirlineCodeToName[AirAsiaAirline]
+		price := internal.Price{Amount: flight.PriceIDR, Currency: "IDR"}
+		mainFare := BuildFare(flightID, flight.CabinClass, price, flight.Seats, baggageData)
+		flightDuration := internal.Duration{TotalMinute: int16(duration.Duration.Minutes()), Formatted: duration.Format()}
+
 		flights = append(flights, internal.Flight{
-			ID:             flight.FlightCode + "_" + mapAirlineCodeToName[AirAsiaAirline],
+			ID:             flightID,
 			Provider:       mapAirlineCodeToName[AirAsiaAirline],
-			Airline:        internal.Airline{Code: mapAirlineCodeToName[AirAsiaAirline], Name: string(AirAsiaAirline)},
+			Airline:        airline,
 			FlightNumber:   flight.FlightCode,
-			Departure:      internal.Airport{Airport: flight.FromAirport, City: departureCity, Datetime: departureTime.Format(), Timestamp: departureTime.Time.Unix()},
-			Arrival:        internal.Airport{Airport: flight.ToAirport, City: arrivalCity, Datetime: arrivalTime.Format(), Timestamp: arrivalTime.Time.Unix()},
-			Duration:       internal.Duration{TotalMinute: int16(duration.Duration.Minutes()), Formatted: duration.Format()},
+			Departure:      departure,
+			Arrival:        arrival,
+			Duration:       flightDuration,
 			Stops:          int8(len(flight.Stops)),
-			Price:          internal.Price{Amount: flight.PriceIDR, Currency: "IDR"},
+			Price:          price,
 			AvailableSeats: flight.Seats,
-			CabinClass:     cabinClass,
+			CabinClass:     mainFare.CabinClass,
 			Aircraft:       nil,
 			Amenities:      nil,
 			Baggage:        baggageData,
 			Layover:        layover,
+			Layovers:       layovers,
+			Segments:       BuildSegments(airline, flight.FlightCode, departure, arrival, nil, flightDuration, layovers),
+			Fares:          []internal.Fare{mainFare},
 		})
 	}
 	return flights
 }
 
-type AirAsia struct{}
+type AirAsia struct {
+	Inventory *SeatInventory
+}
 
 func NewAirAsia() *AirAsia {
-	return &AirAsia{}
+	return &AirAsia{Inventory: NewSeatInventory()}
 }
 
 func (b *AirAsia) GetFlights(origin, destination, departureDate string) (AirAsiaResponse, error) {
@@ -120,6 +128,28 @@ func (b *AirAsia) GetFlights(origin, destination, departureDate string) (AirAsia
 	}
 	var response AirAsiaResponse
 	_ = json.Unmarshal([]byte(strAirasiaMockResponse), &response)
+	for i, flight := range response.Flights {
+		response.Flights[i].Seats -= b.Inventory.Held(flight.FlightCode, departureDate, "")
+	}
 
 	return response, nil
 }
+
+func (b *AirAsia) Hold(request HoldRequest) (string, error) {
+	helper.RandomDelay(50, 150)
+	if !request.isMockRoute() {
+		return "", ErrSeatsUnavailable
+	}
+	var response AirAsiaResponse
+	_ = json.Unmarshal([]byte(strAirasiaMockResponse), &response)
+	for _, flight := range response.Flights {
+		if flight.FlightCode == request.FlightNumber {
+			return b.Inventory.Hold(request.FlightNumber, request.DepartureDate, request.BookingClass, flight.Seats, request.Seats, request.TTL)
+		}
+	}
+	return "", ErrSeatsUnavailable
+}
+
+func (b *AirAsia) Release(holdReference string) error {
+	return b.Inventory.Release(holdReference)
+}
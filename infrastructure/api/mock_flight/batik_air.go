@@ -32,7 +32,7 @@ type BatikFlight struct {
 	TravelTime        string      `json:"travel_time"`
 	NumberOfStops     int8        `json:"numberOfStops"`
 	Fare              IDPrice     `json:"fare"`
-	SeatAvailable     int16       `json:"seatAvailable"`
+	SeatAvailable     int16       `json:"seatsAvailable"`
 	AircraftModel     string      `json:"aircraftModel"`
 	BaggageInfo       string      `json:"baggageInfo"`
 	OnBoardServices   []string    `json:"onBoardServices"`
@@ -77,14 +77,10 @@ func (b BatikAirResponse) Normalize() []internal.Flight {
 			continue
 		}
 
-		class := internal.BusinessClass
-		if flight.Fare.Class == "Y" {
-			class = internal.EconomyClass
-		}
-
 		baggageData := GetBaggageInfo(flight.BaggageInfo)
 
 		layover := 0
+		layovers := []internal.Layover{}
 		for _, stop := range flight.Connections {
 			stopDuration, err := time.ParseDuration(stop.StopDuration)
 			if err != nil {
@@ -92,33 +88,48 @@ func (b BatikAirResponse) Normalize() []internal.Flight {
 				continue
 			}
 			layover += int(stopDuration.Minutes())
+			layovers = append(layovers, internal.Layover{Airport: stop.StopAirport, TotalMinute: int(stopDuration.Minutes())})
 		}
 
+		airline := internal.Airline{Code: mapAirlineCodeToName[BatikAirAirline], Name: string(BatikAirAirline)}
+		departure := internal.Airport{Airport: flight.Origin, City: departureCity, Datetime: departureTime.Format(), Timestamp: departureTime.Time.Unix()}
+		arrival := internal.Airport{Airport: flight.Destination, City: arrivalCity, Datetime: arrivalTime.Format(), Timestamp: arrivalTime.Time.Unix()}
+		flightID := flight.FlightNumber + "_" + mapAirlineCodeToName[BatikAirAirline]
+		price := internal.Price{Amount: flight.Fare.TotalPrice, Currency: flight.Fare.CurrencyCode}
+		mainFare := BuildFare(flightID, flight.Fare.Class, price, flight.SeatAvailable, baggageData)
+		flightDuration := internal.Duration{TotalMinute: int16(duration.Duration.Minutes()), Formatted: duration.Format()}
+		aircraft := GetAircraftInfo(flight.AircraftModel)
+
 		flights = append(flights, internal.Flight{
-			ID:             flight.FlightNumber + "_" + mapAirlineCodeToName[BatikAirAirline],
+			ID:             flightID,
 			Provider:       mapAirlineCodeToName[BatikAirAirline],
-			Airline:        internal.Airline{Code: mapAirlineCodeToName[BatikAirAirline], Name: string(BatikAirAirline)},
+			Airline:        airline,
 			FlightNumber:   flight.FlightNumber,
-			Departure:      internal.Airport{Airport: flight.Origin, City: departureCity, Datetime: departureTime.Format(), Timestamp: departureTime.Time.Unix()},
-			Arrival:        internal.Airport{Airport: flight.Destination, City: arrivalCity, Datetime: arrivalTime.Format(), Timestamp: arrivalTime.Time.Unix()},
-			Duration:       internal.Duration{TotalMinute: int16(duration.Duration.Minutes()), Formatted: duration.Format()},
+			Departure:      departure,
+			Arrival:        arrival,
+			Duration:       flightDuration,
 			Stops:          flight.NumberOfStops,
-			Price:          internal.Price{Amount: flight.Fare.TotalPrice, Currency: flight.Fare.CurrencyCode},
+			Price:          price,
 			AvailableSeats: flight.SeatAvailable,
-			CabinClass:     class,
-			Aircraft:       &flight.AircraftModel,
-			Amenities:      flight.OnBoardServices,
+			CabinClass:     mainFare.CabinClass,
+			Aircraft:       aircraft,
+			Amenities:      NormalizeAmenities(flight.OnBoardServices),
 			Baggage:        baggageData,
 			Layover:        layover,
+			Layovers:       layovers,
+			Segments:       BuildSegments(airline, flight.FlightNumber, departure, arrival, aircraft, flightDuration, layovers),
+			Fares:          []internal.Fare{mainFare},
 		})
 	}
 	return flights
 }
 
-type BatikAir struct{}
+type BatikAir struct {
+	Inventory *SeatInventory
+}
 
 func NewBatikAir() *BatikAir {
-	return &BatikAir{}
+	return &BatikAir{Inventory: NewSeatInventory()}
 }
 
 func (b *BatikAir) GetFlights(origin, destination, departureDate string) (BatikAirResponse, error) {
@@ -128,6 +139,28 @@ func (b *BatikAir) GetFlights(origin, destination, departureDate string) (BatikA
 	}
 	var response BatikAirResponse
 	_ = json.Unmarshal([]byte(strBatikAirMockResponse), &response)
+	for i, flight := range response.Results {
+		response.Results[i].SeatAvailable -= b.Inventory.Held(flight.FlightNumber, departureDate, "")
+	}
 
 	return response, nil
 }
+
+func (b *BatikAir) Hold(request HoldRequest) (string, error) {
+	helper.RandomDelay(200, 400)
+	if !request.isMockRoute() {
+		return "", ErrSeatsUnavailable
+	}
+	var response BatikAirResponse
+	_ = json.Unmarshal([]byte(strBatikAirMockResponse), &response)
+	for _, flight := range response.Results {
+		if flight.FlightNumber == request.FlightNumber {
+			return b.Inventory.Hold(request.FlightNumber, request.DepartureDate, request.BookingClass, flight.SeatAvailable, request.Seats, request.TTL)
+		}
+	}
+	return "", ErrSeatsUnavailable
+}
+
+func (b *BatikAir) Release(holdReference string) error {
+	return b.Inventory.Release(holdReference)
+}
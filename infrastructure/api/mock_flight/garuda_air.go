@@ -5,6 +5,7 @@ import (
 	"kevinjuniawan/bookcabin/internal"
 	"kevinjuniawan/bookcabin/pkg/helper"
 	"log"
+	"time"
 )
 
 type GAAirport struct {
@@ -32,6 +33,13 @@ type GATransit struct {
 	LayoverMinutes  int16     `json:"layover_minutes,omitempty"`
 }
 
+type GAFare struct {
+	BookingClass   string  `json:"booking_class"`
+	FareClass      string  `json:"fare_class"`
+	Price          GAPrice `json:"price"`
+	AvailableSeats int16   `json:"available_seats"`
+}
+
 type GarudaFlight struct {
 	FlightID        string      `json:"flight_id"`
 	Airline         string      `json:"airline"`
@@ -47,6 +55,7 @@ type GarudaFlight struct {
 	Baggage         GABag       `json:"baggage"`
 	Amenities       []string    `json:"amenities"`
 	Segments        []GATransit `json:"segments,omitempty"`
+	Fares           []GAFare    `json:"fares,omitempty"`
 }
 
 func (g GarudaFlight) isValid() bool {
@@ -61,6 +70,34 @@ func (g GarudaFlight) isValid() bool {
 	return true
 }
 
+// NormalizeSegments map every leg, aircraft is only known for the leg operated with the main flight number
+func (g GarudaFlight) NormalizeSegments(airline internal.Airline, aircraft *internal.Aircraft) ([]internal.Segment, bool) {
+	segments := []internal.Segment{}
+	for _, transit := range g.Segments {
+		departureTime, arrivalTime, duration, isValid := ConvertDateTimeToTime("2006-01-02T15:04:05-07:00", transit.Departure.Time, transit.Arrival.Time)
+		if !isValid {
+			return nil, false
+		}
+		if transit.DurationMinutes > 0 {
+			duration.Duration = time.Duration(transit.DurationMinutes) * time.Minute
+		}
+
+		segment := internal.Segment{
+			Airline:       airline,
+			FlightNumber:  transit.FlightNumber,
+			Departure:     internal.Airport{Airport: transit.Departure.Airport, City: mapAirportCodeToCity[transit.Departure.Airport], Datetime: departureTime.Format(), Timestamp: departureTime.Time.Unix()},
+			Arrival:       internal.Airport{Airport: transit.Arrival.Airport, City: mapAirportCodeToCity[transit.Arrival.Airport], Datetime: arrivalTime.Format(), Timestamp: arrivalTime.Time.Unix()},
+			Duration:      &internal.Duration{TotalMinute: int16(duration.Duration.Minutes()), Formatted: duration.Format()},
+			LayoverMinute: int(transit.LayoverMinutes),
+		}
+		if transit.FlightNumber == g.FlightID {
+			segment.Aircraft = aircraft
+		}
+		segments = append(segments, segment)
+	}
+	return segments, true
+}
+
 type GarudaAirResponse struct {
 	Status  string         `json:"status"`
 	Flights []GarudaFlight `json:"flights"`
@@ -93,32 +130,64 @@ func (g GarudaAirResponse) Normalize() []internal.Flight {
 			continue
 		}
 
-		class := internal.BusinessClass
-		if flight.FareClass == "economy" {
-			class = internal.EconomyClass
-		}
-
 		layover := 0
-		for _, transit := range flight.Segments {
+		layovers := []internal.Layover{}
+		for i, transit := range flight.Segments {
 			layover += int(transit.LayoverMinutes)
+			if i > 0 {
+				layovers = append(layovers, internal.Layover{Airport: transit.Departure.Airport, TotalMinute: int(transit.LayoverMinutes)})
+			}
+		}
+
+		stops := flight.Stops
+		if int(stops) < len(layovers) {
+			stops = int8(len(layovers))
+		}
+
+		airline := internal.Airline{Code: mapAirlineCodeToName[GarudaAirline], Name: string(GarudaAirline)}
+		departure := internal.Airport{Airport: flight.Departure.Airport, City: departureCity, Datetime: departureTime.Format(), Timestamp: departureTime.Time.Unix()}
+		arrival := internal.Airport{Airport: arrivalCodeData, City: arrivalCity, Datetime: arrivalTime.Format(), Timestamp: arrivalTime.Time.Unix()}
+		flightID := flight.FlightID + "_" + mapAirlineCodeToName[GarudaAirline]
+		baggage := GetBaggageInfoByType(flight.Baggage.CarryOn, flight.Baggage.Checked)
+		mainFare := BuildFare(flightID, flight.FareClass, internal.Price{Amount: flight.Price.Amount, Currency: flight.Price.Currency}, flight.AvailableSeats, baggage)
+		fares := []internal.Fare{mainFare}
+		if len(flight.Fares) > 0 {
+			fares = []internal.Fare{}
+			for _, fare := range flight.Fares {
+				fares = append(fares, BuildFare(flightID, fare.BookingClass, internal.Price{Amount: fare.Price.Amount, Currency: fare.Price.Currency}, fare.AvailableSeats, baggage))
+			}
+		}
+		flightDuration := internal.Duration{TotalMinute: int16(duration.Duration.Minutes()), Formatted: duration.Format()}
+		aircraft := GetAircraftInfo(flight.Aircraft)
+
+		segments := BuildSegments(airline, flight.FlightID, departure, arrival, aircraft, flightDuration, nil)
+		if len(flight.Segments) > 0 {
+			segments, isValid = flight.NormalizeSegments(airline, aircraft)
+			if !isValid {
+				log.Printf("[%s - %s] fail parse segments\n", GarudaAirline, flight.FlightID)
+				continue
+			}
 		}
 
 		flights = append(flights, internal.Flight{
-			ID:             flight.FlightID + "_" + mapAirlineCodeToName[GarudaAirline],
+			ID:             flightID,
 			Provider:       mapAirlineCodeToName[GarudaAirline],
-			Airline:        internal.Airline{Code: mapAirlineCodeToName[GarudaAirline], Name: string(GarudaAirline)},
+			Airline:        airline,
 			FlightNumber:   flight.FlightID,
-			Departure:      internal.Airport{Airport: flight.Departure.Airport, City: departureCity, Datetime: departureTime.Format(), Timestamp: departureTime.Time.Unix()},
-			Arrival:        internal.Airport{Airport: flight.Arrival.Airport, City: arrivalCity, Datetime: arrivalTime.Format(), Timestamp: arrivalTime.Time.Unix()},
-			Duration:       internal.Duration{TotalMinute: int16(duration.Duration.Minutes()), Formatted: duration.Format()},
-			Stops:          flight.Stops,
+			Departure:      departure,
+			Arrival:        arrival,
+			Duration:       flightDuration,
+			Stops:          stops,
 			Price:          internal.Price{Amount: flight.Price.Amount, Currency: flight.Price.Currency},
 			AvailableSeats: flight.AvailableSeats,
-			CabinClass:     class,
-			Aircraft:       &flight.Aircraft,
-			Amenities:      flight.Amenities,
-			Baggage:        internal.Bag{CarryOn: mapBaggageTypeToText[flight.Baggage.CarryOn], Checked: mapBaggageTypeToText[flight.Baggage.Checked]},
+			CabinClass:     mainFare.CabinClass,
+			Aircraft:       aircraft,
+			Amenities:      NormalizeAmenities(flight.Amenities),
+			Baggage:        baggage,
 			Layover:        layover,
+			Layovers:       layovers,
+			Segments:       segments,
+			Fares:          fares,
 		})
 
 	}
@@ -126,10 +195,12 @@ func (g GarudaAirResponse) Normalize() []internal.Flight {
 	return flights
 }
 
-type GarudaAir struct{}
+type GarudaAir struct {
+	Inventory *SeatInventory
+}
 
 func NewGarudaAir() *GarudaAir {
-	return &GarudaAir{}
+	return &GarudaAir{Inventory: NewSeatInventory()}
 }
 
 func (b *GarudaAir) GetFlights(origin, destination, departureDate string) (GarudaAirResponse, error) {
@@ -140,6 +211,42 @@ func (b *GarudaAir) GetFlights(origin, destination, departureDate string) (Garud
 
 	var response GarudaAirResponse
 	_ = json.Unmarshal([]byte(strGarudaAirMockResponse), &response)
+	for i, flight := range response.Flights {
+		response.Flights[i].AvailableSeats -= b.Inventory.Held(flight.FlightID, departureDate, "")
+		for j, fare := range flight.Fares {
+			response.Flights[i].Fares[j].AvailableSeats -= b.Inventory.Held(flight.FlightID, departureDate, fare.BookingClass)
+		}
+	}
 
 	return response, nil
 }
+
+// Hold check the booking class seats when the flight sell several fares, otherwise the flight seats
+func (b *GarudaAir) Hold(request HoldRequest) (string, error) {
+	helper.RandomDelay(50, 150)
+	if !request.isMockRoute() {
+		return "", ErrSeatsUnavailable
+	}
+	var response GarudaAirResponse
+	_ = json.Unmarshal([]byte(strGarudaAirMockResponse), &response)
+	for _, flight := range response.Flights {
+		if flight.FlightID != request.FlightNumber {
+			continue
+		}
+		capacity := flight.AvailableSeats
+		for _, fare := range flight.Fares {
+			if fare.BookingClass == request.BookingClass {
+				capacity = fare.AvailableSeats
+			}
+		}
+		if flight.AvailableSeats-b.Inventory.Held(flight.FlightID, request.DepartureDate, "") < request.Seats {
+			return "", ErrSeatsUnavailable
+		}
+		return b.Inventory.Hold(request.FlightNumber, request.DepartureDate, request.BookingClass, capacity, request.Seats, request.TTL)
+	}
+	return "", ErrSeatsUnavailable
+}
+
+func (b *GarudaAir) Release(holdReference string) error {
+	return b.Inventory.Release(holdReference)
+}
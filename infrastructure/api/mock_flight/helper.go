@@ -4,26 +4,37 @@ import (
 	"fmt"
 	"kevinjuniawan/bookcabin/internal"
 	"log"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
 
 const strBatikAirMockResponse = `{"code":200,"message":"OK","results":[{"flightNumber":"ID6514","airlineName":"Batik Air","airlineIATA":"ID","origin":"CGK","destination":"DPS","departureDateTime":"2025-12-15T07:15:00+0700","arrivalDateTime":"2025-12-15T10:00:00+0800","travelTime":"1h 45m","numberOfStops":0,"fare":{"basePrice":980000,"taxes":120000,"totalPrice":1100000,"currencyCode":"IDR","class":"Y"},"seatsAvailable":32,"aircraftModel":"Airbus A320","baggageInfo":"7kg cabin, 20kg checked","onboardServices":["Snack","Beverage"]},{"flightNumber":"ID6520","airlineName":"Batik Air","airlineIATA":"ID","origin":"CGK","destination":"DPS","departureDateTime":"2025-12-15T13:30:00+0700","arrivalDateTime":"2025-12-15T16:20:00+0800","travelTime":"1h 50m","numberOfStops":0,"fare":{"basePrice":1050000,"taxes":130000,"totalPrice":1180000,"currencyCode":"IDR","class":"Y"},"seatsAvailable":18,"aircraftModel":"Boeing 737-800","baggageInfo":"7kg cabin, 20kg checked","onboardServices":["Meal","Beverage","Entertainment"]},{"flightNumber":"ID7042","airlineName":"Batik Air","airlineIATA":"ID","origin":"CGK","destination":"DPS","departureDateTime":"2025-12-15T18:45:00+0700","arrivalDateTime":"2025-12-15T23:50:00+0800","travelTime":"3h 5m","numberOfStops":1,"connections":[{"stopAirport":"UPG","stopDuration":"55m"}],"fare":{"basePrice":850000,"taxes":100000,"totalPrice":950000,"currencyCode":"IDR","class":"Y"},"seatsAvailable":41,"aircraftModel":"Airbus A320","baggageInfo":"7kg cabin, 20kg checked","onboardServices":["Snack"]}]}`
 const strLionAirMockResponse = `{"success":true,"data":{"available_flights":[{"id":"JT740","carrier":{"name":"Lion Air","iata":"JT"},"route":{"from":{"code":"CGK","name":"Soekarno-Hatta International","city":"Jakarta"},"to":{"code":"DPS","name":"Ngurah Rai International","city":"Denpasar"}},"schedule":{"departure":"2025-12-15T05:30:00","departure_timezone":"Asia/Jakarta","arrival":"2025-12-15T08:15:00","arrival_timezone":"Asia/Makassar"},"flight_time":105,"is_direct":true,"pricing":{"total":950000,"currency":"IDR","fare_type":"ECONOMY"},"seats_left":45,"plane_type":"Boeing 737-900ER","services":{"wifi_available":false,"meals_included":false,"baggage_allowance":{"cabin":"7 kg","hold":"20 kg"}}},{"id":"JT742","carrier":{"name":"Lion Air","iata":"JT"},"route":{"from":{"code":"CGK","name":"Soekarno-Hatta International","city":"Jakarta"},"to":{"code":"DPS","name":"Ngurah Rai International","city":"Denpasar"}},"schedule":{"departure":"2025-12-15T11:45:00","departure_timezone":"Asia/Jakarta","arrival":"2025-12-15T14:35:00","arrival_timezone":"Asia/Makassar"},"flight_time":110,"is_direct":true,"pricing":{"total":890000,"currency":"IDR","fare_type":"ECONOMY"},"seats_left":38,"plane_type":"Boeing 737-800","services":{"wifi_available":false,"meals_included":false,"baggage_allowance":{"cabin":"7 kg","hold":"20 kg"}}},{"id":"JT650","carrier":{"name":"Lion Air","iata":"JT"},"route":{"from":{"code":"CGK","name":"Soekarno-Hatta International","city":"Jakarta"},"to":{"code":"DPS","name":"Ngurah Rai International","city":"Denpasar"}},"schedule":{"departure":"2025-12-15T16:20:00","departure_timezone":"Asia/Jakarta","arrival":"2025-12-15T21:10:00","arrival_timezone":"Asia/Makassar"},"flight_time":230,"is_direct":false,"stop_count":1,"layovers":[{"airport":"SUB","duration_minutes":75}],"pricing":{"total":780000,"currency":"IDR","fare_type":"ECONOMY"},"seats_left":52,"plane_type":"Boeing 737-800","services":{"wifi_available":false,"meals_included":false,"baggage_allowance":{"cabin":"7 kg","hold":"20 kg"}}}]}}`
-const strGarudaAirMockResponse = `{"status":"success","flights":[{"flight_id":"GA400","airline":"Garuda Indonesia","airline_code":"GA","departure":{"airport":"CGK","city":"Jakarta","time":"2025-12-15T06:00:00+07:00","terminal":"3"},"arrival":{"airport":"DPS","city":"Denpasar","time":"2025-12-15T08:50:00+08:00","terminal":"I"},"duration_minutes":110,"stops":0,"aircraft":"Boeing 737-800","price":{"amount":1250000,"currency":"IDR"},"available_seats":28,"fare_class":"economy","baggage":{"carry_on":1,"checked":2},"amenities":["wifi","meal","entertainment"]},{"flight_id":"GA410","airline":"Garuda Indonesia","airline_code":"GA","departure":{"airport":"CGK","city":"Jakarta","time":"2025-12-15T09:30:00+07:00","terminal":"3"},"arrival":{"airport":"DPS","city":"Denpasar","time":"2025-12-15T12:25:00+08:00","terminal":"I"},"duration_minutes":115,"stops":0,"aircraft":"Airbus A330-300","price":{"amount":1450000,"currency":"IDR"},"available_seats":15,"fare_class":"economy","baggage":{"carry_on":1,"checked":2},"amenities":["wifi","power_outlet","meal","entertainment"]},{"flight_id":"GA315","airline":"Garuda Indonesia","airline_code":"GA","departure":{"airport":"CGK","city":"Jakarta","time":"2025-12-15T14:00:00+07:00","terminal":"3"},"arrival":{"airport":"SUB","city":"Surabaya","time":"2025-12-15T15:30:00+07:00","terminal":"2"},"duration_minutes":90,"stops":0,"aircraft":"Boeing 737","price":{"amount":1850000,"currency":"IDR"},"segments":[{"flight_number":"GA315","departure":{"airport":"CGK","time":"2025-12-15T14:00:00+07:00"},"arrival":{"airport":"SUB","time":"2025-12-15T15:30:00+07:00"},"duration_minutes":90},{"flight_number":"GA332","departure":{"airport":"SUB","time":"2025-12-15T17:15:00+07:00"},"arrival":{"airport":"DPS","time":"2025-12-15T18:45:00+08:00"},"duration_minutes":90,"layover_minutes":105}],"available_seats":22,"fare_class":"economy","baggage":{"carry_on":1,"checked":2}}]}`
+const strGarudaAirMockResponse = `{"status":"success","flights":[{"flight_id":"GA400","airline":"Garuda Indonesia","airline_code":"GA","departure":{"airport":"CGK","city":"Jakarta","time":"2025-12-15T06:00:00+07:00","terminal":"3"},"arrival":{"airport":"DPS","city":"Denpasar","time":"2025-12-15T08:50:00+08:00","terminal":"I"},"duration_minutes":110,"stops":0,"aircraft":"Boeing 737-800","price":{"amount":1250000,"currency":"IDR"},"available_seats":28,"fare_class":"economy","baggage":{"carry_on":1,"checked":2},"amenities":["wifi","meal","entertainment"],"fares":[{"booking_class":"K","fare_class":"economy","price":{"amount":1250000,"currency":"IDR"},"available_seats":12},{"booking_class":"Y","fare_class":"economy","price":{"amount":1650000,"currency":"IDR"},"available_seats":16}]},{"flight_id":"GA410","airline":"Garuda Indonesia","airline_code":"GA","departure":{"airport":"CGK","city":"Jakarta","time":"2025-12-15T09:30:00+07:00","terminal":"3"},"arrival":{"airport":"DPS","city":"Denpasar","time":"2025-12-15T12:25:00+08:00","terminal":"I"},"duration_minutes":115,"stops":0,"aircraft":"Airbus A330-300","price":{"amount":1450000,"currency":"IDR"},"available_seats":15,"fare_class":"economy","baggage":{"carry_on":1,"checked":2},"amenities":["wifi","power_outlet","meal","entertainment"],"fares":[{"booking_class":"K","fare_class":"economy","price":{"amount":1450000,"currency":"IDR"},"available_seats":9},{"booking_class":"Y","fare_class":"economy","price":{"amount":1850000,"currency":"IDR"},"available_seats":6},{"booking_class":"I","fare_class":"business","price":{"amount":3900000,"currency":"IDR"},"available_seats":4},{"booking_class":"C","fare_class":"business","price":{"amount":4800000,"currency":"IDR"},"available_seats":4}]},{"flight_id":"GA315","airline":"Garuda Indonesia","airline_code":"GA","departure":{"airport":"CGK","city":"Jakarta","time":"2025-12-15T14:00:00+07:00","terminal":"3"},"arrival":{"airport":"SUB","city":"Surabaya","time":"2025-12-15T15:30:00+07:00","terminal":"2"},"duration_minutes":90,"stops":0,"aircraft":"Boeing 737","price":{"amount":1850000,"currency":"IDR"},"segments":[{"flight_number":"GA315","departure":{"airport":"CGK","time":"2025-12-15T14:00:00+07:00"},"arrival":{"airport":"SUB","time":"2025-12-15T15:30:00+07:00"},"duration_minutes":90},{"flight_number":"GA332","departure":{"airport":"SUB","time":"2025-12-15T17:15:00+07:00"},"arrival":{"airport":"DPS","time":"2025-12-15T18:45:00+08:00"},"duration_minutes":90,"layover_minutes":105}],"available_seats":22,"fare_class":"economy","baggage":{"carry_on":1,"checked":2}}]}`
 const strAirasiaMockResponse = `{"status":"ok","flights":[{"flight_code":"QZ520","airline":"AirAsia","from_airport":"CGK","to_airport":"DPS","depart_time":"2025-12-15T04:45:00+07:00","arrive_time":"2025-12-15T07:25:00+08:00","duration_hours":1.67,"direct_flight":true,"price_idr":650000,"seats":67,"cabin_class":"economy","baggage_note":"Cabin baggage only, checked bags additional fee"},{"flight_code":"QZ524","airline":"AirAsia","from_airport":"CGK","to_airport":"DPS","depart_time":"2025-12-15T10:00:00+07:00","arrive_time":"2025-12-15T12:45:00+08:00","duration_hours":1.75,"direct_flight":true,"price_idr":720000,"seats":54,"cabin_class":"economy","baggage_note":"Cabin baggage only, checked bags additional fee"},{"flight_code":"QZ532","airline":"AirAsia","from_airport":"CGK","to_airport":"DPS","depart_time":"2025-12-15T19:30:00+07:00","arrive_time":"2025-12-15T22:10:00+08:00","duration_hours":1.67,"direct_flight":true,"price_idr":595000,"seats":72,"cabin_class":"economy","baggage_note":"Cabin baggage only, checked bags additional fee"},{"flight_code":"QZ7250","airline":"AirAsia","from_airport":"CGK","to_airport":"DPS","depart_time":"2025-12-15T15:15:00+07:00","arrive_time":"2025-12-15T20:35:00+08:00","duration_hours":4.33,"direct_flight":false,"stops":[{"airport":"SOC","wait_time_minutes":95}],"price_idr":485000,"seats":88,"cabin_class":"economy","baggage_note":"Cabin baggage only, checked bags additional fee"}]}`
 
 var mapAirportCodeToCity = map[string]string{
 	"CGK": "Jakarta",
 	"DPS": "Denpasar",
+	"SUB": "Surabaya",
+	"UPG": "Makassar",
+	"SOC": "Solo",
 }
 
-var mapBaggageTypeToText = map[int8]string{
-	1: "Not Available",
-	2: "Allowed",
-	3: "Additional Fee",
+var mapBaggageTypeToAllowance = map[int8]internal.BaggageAllowance{
+	1: {Type: internal.BaggageNotAvailable},
+	2: {Type: internal.BaggageIncluded, Pieces: 1},
+	3: {Type: internal.BaggagePaid},
 }
 
+var (
+	baggageWeightPattern = regexp.MustCompile(`(\d+)\s*kg`)
+	baggagePiecePattern  = regexp.MustCompile(`(\d+)\s*(pc|pcs|piece|pieces)\b`)
+)
+
 func GetCityNameFromAirportCode(originCode string, destinationCode string) (origin string, destination string, isValid bool) {
 	departureCity := ""
 	if departureCityCode, exist := mapAirportCodeToCity[originCode]; !exist {
@@ -42,6 +53,43 @@ func GetCityNameFromAirportCode(originCode string, destinationCode string) (orig
 	return departureCity, arrivalCity, true
 }
 
+// BuildSegments split flight into legs using the connection airports, leg other than direct flight only know its airports
+func BuildSegments(airline internal.Airline, flightNumber string, departure, arrival internal.Airport, aircraft *internal.Aircraft, duration internal.Duration, layovers []internal.Layover) []internal.Segment {
+	if len(layovers) == 0 {
+		return []internal.Segment{{
+			Airline:      airline,
+			FlightNumber: flightNumber,
+			Departure:    departure,
+			Arrival:      arrival,
+			Duration:     &duration,
+			Aircraft:     aircraft,
+		}}
+	}
+
+	segments := []internal.Segment{}
+	legDeparture := departure
+	for i := 0; i <= len(layovers); i++ {
+		legArrival := arrival
+		if i < len(layovers) {
+			legArrival = internal.Airport{Airport: layovers[i].Airport, City: mapAirportCodeToCity[layovers[i].Airport]}
+		}
+		segment := internal.Segment{
+			Airline:   airline,
+			Departure: legDeparture,
+			Arrival:   legArrival,
+			IsPartial: true,
+		}
+		if i == 0 {
+			segment.FlightNumber = flightNumber
+		} else {
+			segment.LayoverMinute = layovers[i-1].TotalMinute
+		}
+		segments = append(segments, segment)
+		legDeparture = internal.Airport{Airport: legArrival.Airport, City: legArrival.City}
+	}
+	return segments
+}
+
 type FlightTime struct {
 	Time time.Time
 }
@@ -85,14 +133,197 @@ func ConvertDateTimeToTime(format string, departureDateTime string, arrivalDateT
 
 func GetBaggageInfo(baggageData string) internal.Bag {
 	baggageProviderData := strings.Split(baggageData, ",")
-	baggage := internal.Bag{}
+	baggage := internal.Bag{
+		CarryOn: internal.BaggageAllowance{Type: internal.BaggageNotAvailable},
+		Checked: internal.BaggageAllowance{Type: internal.BaggageNotAvailable},
+	}
 	if len(baggageProviderData) > 0 {
-		baggage.CarryOn = baggageProviderData[0]
-		baggage.Checked = baggageProviderData[1]
+		baggage.CarryOn = ParseBaggageAllowance(baggageProviderData[0])
+	}
+	if len(baggageProviderData) > 1 {
+		baggage.Checked = ParseBaggageAllowance(baggageProviderData[1])
+	}
+	return baggage
+}
+
+func GetBaggageInfoByType(carryOnType, checkedType int8) internal.Bag {
+	baggage := internal.Bag{
+		CarryOn: internal.BaggageAllowance{Type: internal.BaggageNotAvailable},
+		Checked: internal.BaggageAllowance{Type: internal.BaggageNotAvailable},
+	}
+	if allowance, exist := mapBaggageTypeToAllowance[carryOnType]; exist {
+		baggage.CarryOn = allowance
+	}
+	if allowance, exist := mapBaggageTypeToAllowance[checkedType]; exist {
+		baggage.Checked = allowance
 	}
 	return baggage
 }
 
+// ParseBaggageAllowance read provider free text such as "7kg cabin", "20 kg" or "checked bags additional fee"
+func ParseBaggageAllowance(baggageText string) internal.BaggageAllowance {
+	text := strings.ToLower(strings.TrimSpace(baggageText))
+	if text == "" || strings.Contains(text, "not available") {
+		return internal.BaggageAllowance{Type: internal.BaggageNotAvailable}
+	}
+	if strings.Contains(text, "fee") || strings.Contains(text, "paid") {
+		return internal.BaggageAllowance{Type: internal.BaggagePaid}
+	}
+
+	allowance := internal.BaggageAllowance{Type: internal.BaggageIncluded, Pieces: 1}
+	if match := baggageWeightPattern.FindStringSubmatch(text); len(match) == 2 {
+		weight, _ := strconv.Atoi(match[1])
+		if weight == 0 {
+			return internal.BaggageAllowance{Type: internal.BaggageNotAvailable}
+		}
+		allowance.WeightKg = int16(weight)
+	}
+	if match := baggagePiecePattern.FindStringSubmatch(text); len(match) == 3 {
+		pieces, _ := strconv.Atoi(match[1])
+		allowance.Pieces = int8(pieces)
+	}
+	return allowance
+}
+
+var mapProviderAmenity = map[string]internal.Amenity{
+	"wifi":          internal.AmenityWifi,
+	"wi-fi":         internal.AmenityWifi,
+	"meal":          internal.AmenityMeal,
+	"meals":         internal.AmenityMeal,
+	"snack":         internal.AmenitySnack,
+	"beverage":      internal.AmenityBeverage,
+	"entertainment": internal.AmenityEntertainment,
+	"power_outlet":  internal.AmenityPowerOutlet,
+	"power outlet":  internal.AmenityPowerOutlet,
+	"usb":           internal.AmenityPowerOutlet,
+}
+
+func NormalizeAmenities(providerAmenities []string) []internal.Amenity {
+	amenities := []internal.Amenity{}
+	for _, providerAmenity := range providerAmenities {
+		amenity, exist := mapProviderAmenity[strings.ToLower(strings.TrimSpace(providerAmenity))]
+		if !exist {
+			log.Printf("unknown amenity %s\n", providerAmenity)
+			continue
+		}
+		if !slices.Contains(amenities, amenity) {
+			amenities = append(amenities, amenity)
+		}
+	}
+	return amenities
+}
+
+type aircraftFamily struct {
+	Family string
+	Body   internal.AircraftBody
+}
+
+// Ordered so more specific family is checked first, e.g. A321 before A320
+var aircraftFamilies = []aircraftFamily{
+	{Family: "737", Body: internal.NarrowBody},
+	{Family: "A321", Body: internal.NarrowBody},
+	{Family: "A320", Body: internal.NarrowBody},
+	{Family: "ATR 72", Body: internal.NarrowBody},
+	{Family: "A330", Body: internal.WideBody},
+	{Family: "A350", Body: internal.WideBody},
+	{Family: "777", Body: internal.WideBody},
+	{Family: "787", Body: internal.WideBody},
+}
+
+func GetAircraftInfo(model string) *internal.Aircraft {
+	model = strings.TrimSpace(model)
+	if model == "" {
+		return nil
+	}
+	aircraft := &internal.Aircraft{Model: model}
+	for _, family := range aircraftFamilies {
+		if strings.Contains(strings.ToUpper(model), family.Family) {
+			aircraft.Family = family.Family
+			aircraft.Body = family.Body
+			break
+		}
+	}
+	return aircraft
+}
+
+var mapBookingClassToFareFamily = map[string]internal.FareFamily{
+	"Y": internal.FareFamilyEconomyFlex,
+	"B": internal.FareFamilyEconomyFlex,
+	"M": internal.FareFamilyEconomyClassic,
+	"H": internal.FareFamilyEconomyClassic,
+	"K": internal.FareFamilyEconomyLite,
+	"L": internal.FareFamilyEconomyLite,
+	"Q": internal.FareFamilyEconomyLite,
+	"V": internal.FareFamilyEconomyLite,
+	"J": internal.FareFamilyBusinessFlex,
+	"C": internal.FareFamilyBusinessFlex,
+	"D": internal.FareFamilyBusinessFlex,
+	"I": internal.FareFamilyBusinessSaver,
+	"Z": internal.FareFamilyBusinessSaver,
+	"W": internal.FareFamilyPremiumEconomy,
+	"E": internal.FareFamilyPremiumEconomy,
+	"F": internal.FareFamilyFirst,
+	"A": internal.FareFamilyFirst,
+}
+
+// Provider sending fare type or cabin instead of booking class is mapped to its standard booking class
+var mapFareTypeToBookingClass = map[string]string{
+	"economy":         "M",
+	"promo":           "K",
+	"premium_economy": "W",
+	"premium economy": "W",
+	"business":        "C",
+	"first":           "F",
+}
+
+var mapFareFamilyToCabin = map[internal.FareFamily]internal.Class{
+	internal.FareFamilyEconomyLite:    internal.EconomyClass,
+	internal.FareFamilyEconomyClassic: internal.EconomyClass,
+	internal.FareFamilyEconomyFlex:    internal.EconomyClass,
+	internal.FareFamilyPremiumEconomy: internal.PremiumEconomyClass,
+	internal.FareFamilyBusinessSaver:  internal.BusinessClass,
+	internal.FareFamilyBusinessFlex:   internal.BusinessClass,
+	internal.FareFamilyFirst:          internal.FirstClass,
+}
+
+var mapFareFamilyToConditions = map[internal.FareFamily]internal.FareConditions{
+	internal.FareFamilyEconomyLite:    {Refundable: false, Changeable: false},
+	internal.FareFamilyEconomyClassic: {Refundable: false, Changeable: true, ChangeFee: 250000, CheckedBaggage: true},
+	internal.FareFamilyEconomyFlex:    {Refundable: true, RefundFee: 150000, Changeable: true, SeatSelection: true, CheckedBaggage: true},
+	internal.FareFamilyBusinessSaver:  {Refundable: true, RefundFee: 500000, Changeable: true, ChangeFee: 300000, SeatSelection: true, CheckedBaggage: true},
+	internal.FareFamilyPremiumEconomy: {Refundable: true, RefundFee: 250000, Changeable: true, ChangeFee: 150000, SeatSelection: true, CheckedBaggage: true},
+	internal.FareFamilyBusinessFlex:   {Refundable: true, Changeable: true, SeatSelection: true, CheckedBaggage: true},
+	internal.FareFamilyFirst:          {Refundable: true, Changeable: true, SeatSelection: true, CheckedBaggage: true},
+}
+
+func GetBookingClass(fareType string) string {
+	fareType = strings.TrimSpace(fareType)
+	if _, exist := mapBookingClassToFareFamily[strings.ToUpper(fareType)]; exist {
+		return strings.ToUpper(fareType)
+	}
+	if bookingClass, exist := mapFareTypeToBookingClass[strings.ToLower(fareType)]; exist {
+		return bookingClass
+	}
+	return mapFareTypeToBookingClass["economy"]
+}
+
+// BuildFare map booking class into its fare family, checked baggage condition follow what provider include on the flight
+func BuildFare(flightID, bookingClass string, price internal.Price, seats int16, baggage internal.Bag) internal.Fare {
+	bookingClass = GetBookingClass(bookingClass)
+	family := mapBookingClassToFareFamily[bookingClass]
+	conditions := mapFareFamilyToConditions[family]
+	conditions.CheckedBaggage = conditions.CheckedBaggage && baggage.Checked.IsIncluded()
+	return internal.Fare{
+		ID:             flightID + "_" + bookingClass,
+		BookingClass:   bookingClass,
+		FareFamily:     family,
+		CabinClass:     mapFareFamilyToCabin[family],
+		Price:          price,
+		AvailableSeats: seats,
+		Conditions:     conditions,
+	}
+}
+
 type AirlineCode string
 
 const (
@@ -108,3 +339,7 @@ var mapAirlineCodeToName = map[AirlineCode]string{
 	LionAirAirline:  "LionAir",
 	BatikAirAirline: "BatikAir",
 }
+
+func GetProviderName(code AirlineCode) string {
+	return mapAirlineCodeToName[code]
+}
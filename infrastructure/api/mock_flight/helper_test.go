@@ -0,0 +1,127 @@
+package mockflight
+
+import (
+	"testing"
+	"time"
+
+	"kevinjuniawan/bookcabin/internal"
+)
+
+func TestParseBaggageAllowance(t *testing.T) {
+	tests := []struct {
+		text string
+		want internal.BaggageAllowance
+	}{
+		{text: "7kg cabin", want: internal.BaggageAllowance{Type: internal.BaggageIncluded, Pieces: 1, WeightKg: 7}},
+		{text: " 20 KG checked ", want: internal.BaggageAllowance{Type: internal.BaggageIncluded, Pieces: 1, WeightKg: 20}},
+		{text: "2 pcs 23kg", want: internal.BaggageAllowance{Type: internal.BaggageIncluded, Pieces: 2, WeightKg: 23}},
+		{text: "1 piece", want: internal.BaggageAllowance{Type: internal.BaggageIncluded, Pieces: 1}},
+		{text: "Cabin baggage only", want: internal.BaggageAllowance{Type: internal.BaggageIncluded, Pieces: 1}},
+		{text: "0kg", want: internal.BaggageAllowance{Type: internal.BaggageNotAvailable}},
+		{text: "checked bags additional fee", want: internal.BaggageAllowance{Type: internal.BaggagePaid}},
+		{text: "paid baggage", want: internal.BaggageAllowance{Type: internal.BaggagePaid}},
+		{text: "not available", want: internal.BaggageAllowance{Type: internal.BaggageNotAvailable}},
+		{text: "", want: internal.BaggageAllowance{Type: internal.BaggageNotAvailable}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := ParseBaggageAllowance(tt.text); got != tt.want {
+				t.Errorf("ParseBaggageAllowance(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetBaggageInfo(t *testing.T) {
+	notAvailable := internal.BaggageAllowance{Type: internal.BaggageNotAvailable}
+	tests := []struct {
+		name string
+		data string
+		want internal.Bag
+	}{
+		{
+			name: "carry on and checked",
+			data: "7kg cabin, 20kg checked",
+			want: internal.Bag{
+				CarryOn: internal.BaggageAllowance{Type: internal.BaggageIncluded, Pieces: 1, WeightKg: 7},
+				Checked: internal.BaggageAllowance{Type: internal.BaggageIncluded, Pieces: 1, WeightKg: 20},
+			},
+		},
+		{
+			name: "paid checked",
+			data: "Cabin baggage only, checked bags additional fee",
+			want: internal.Bag{
+				CarryOn: internal.BaggageAllowance{Type: internal.BaggageIncluded, Pieces: 1},
+				Checked: internal.BaggageAllowance{Type: internal.BaggagePaid},
+			},
+		},
+		{
+			name: "carry on only",
+			data: "7kg cabin",
+			want: internal.Bag{CarryOn: internal.BaggageAllowance{Type: internal.BaggageIncluded, Pieces: 1, WeightKg: 7}, Checked: notAvailable},
+		},
+		{name: "empty", data: "", want: internal.Bag{CarryOn: notAvailable, Checked: notAvailable}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetBaggageInfo(tt.data); got != tt.want {
+				t.Errorf("GetBaggageInfo(%q) = %+v, want %+v", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetBaggageInfoByType(t *testing.T) {
+	tests := []struct {
+		name        string
+		carryOn     int8
+		checked     int8
+		wantCarryOn internal.BaggageType
+		wantChecked internal.BaggageType
+	}{
+		{name: "included", carryOn: 2, checked: 2, wantCarryOn: internal.BaggageIncluded, wantChecked: internal.BaggageIncluded},
+		{name: "paid checked", carryOn: 2, checked: 3, wantCarryOn: internal.BaggageIncluded, wantChecked: internal.BaggagePaid},
+		{name: "not available", carryOn: 1, checked: 1, wantCarryOn: internal.BaggageNotAvailable, wantChecked: internal.BaggageNotAvailable},
+		{name: "unknown type", carryOn: 9, checked: 0, wantCarryOn: internal.BaggageNotAvailable, wantChecked: internal.BaggageNotAvailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GetBaggageInfoByType(tt.carryOn, tt.checked)
+			if got.CarryOn.Type != tt.wantCarryOn || got.Checked.Type != tt.wantChecked {
+				t.Errorf("GetBaggageInfoByType(%d, %d) = %s %s, want %s %s", tt.carryOn, tt.checked, got.CarryOn.Type, got.Checked.Type, tt.wantCarryOn, tt.wantChecked)
+			}
+		})
+	}
+}
+
+func TestConvertDateTimeToTime(t *testing.T) {
+	tests := []struct {
+		name          string
+		departure     string
+		arrival       string
+		wantValid     bool
+		wantDuration  time.Duration
+		wantFormatted string
+	}{
+		{name: "same timezone", departure: "2025-12-15T14:00:00+07:00", arrival: "2025-12-15T15:30:00+07:00", wantValid: true, wantDuration: 90 * time.Minute, wantFormatted: "1h 30m"},
+		{name: "across timezone", departure: "2025-12-15T06:00:00+07:00", arrival: "2025-12-15T08:50:00+08:00", wantValid: true, wantDuration: 110 * time.Minute, wantFormatted: "1h 50m"},
+		{name: "overnight", departure: "2025-12-15T22:00:00+07:00", arrival: "2025-12-16T00:05:00+07:00", wantValid: true, wantDuration: 125 * time.Minute, wantFormatted: "2h 5m"},
+		{name: "arrival before departure", departure: "2025-12-15T10:00:00+07:00", arrival: "2025-12-15T09:00:00+07:00"},
+		{name: "invalid departure", departure: "2025-12-15 10:00", arrival: "2025-12-15T11:00:00+07:00"},
+		{name: "invalid arrival", departure: "2025-12-15T10:00:00+07:00", arrival: "tomorrow"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, duration, isValid := ConvertDateTimeToTime(time.RFC3339, tt.departure, tt.arrival)
+			if isValid != tt.wantValid {
+				t.Fatalf("ConvertDateTimeToTime() valid = %v, want %v", isValid, tt.wantValid)
+			}
+			if !tt.wantValid {
+				return
+			}
+			if duration.Duration != tt.wantDuration || duration.Format() != tt.wantFormatted {
+				t.Errorf("ConvertDateTimeToTime() duration = %v %s, want %v %s", duration.Duration, duration.Format(), tt.wantDuration, tt.wantFormatted)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package mockflight
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSeatsUnavailable = errors.New("seats are not available")
+	ErrHoldNotFound     = errors.New("hold is not found")
+)
+
+type HoldRequest struct {
+	Origin        string
+	Destination   string
+	DepartureDate string
+	FlightNumber  string
+	BookingClass  string
+	Seats         int16
+	TTL           time.Duration
+}
+
+func (r HoldRequest) isMockRoute() bool {
+	return r.Origin == "CGK" && r.Destination == "DPS" && r.DepartureDate == "2025-12-15"
+}
+
+type seatHold struct {
+	Key       string
+	Seats     int16
+	ExpiredAt time.Time
+}
+
+// SeatInventory keep seats held by bookings in memory, seats from mock response are reduced by the held seats
+type SeatInventory struct {
+	mu    sync.Mutex
+	holds map[string]seatHold
+}
+
+func NewSeatInventory() *SeatInventory {
+	return &SeatInventory{
+		holds: map[string]seatHold{},
+	}
+}
+
+func inventoryKey(flightNumber, departureDate, bookingClass string) string {
+	return flightNumber + ":" + departureDate + ":" + bookingClass
+}
+
+// Held return seats held for the booking class, empty booking class return seats held for the whole flight
+func (i *SeatInventory) Held(flightNumber, departureDate, bookingClass string) int16 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.purgeExpired()
+
+	held := int16(0)
+	key := inventoryKey(flightNumber, departureDate, bookingClass)
+	for _, hold := range i.holds {
+		if hold.Key == key || (bookingClass == "" && strings.HasPrefix(hold.Key, key)) {
+			held += hold.Seats
+		}
+	}
+	return held
+}
+
+func (i *SeatInventory) Hold(flightNumber, departureDate, bookingClass string, capacity, seats int16, ttl time.Duration) (string, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.purgeExpired()
+
+	key := inventoryKey(flightNumber, departureDate, bookingClass)
+	held := int16(0)
+	for _, hold := range i.holds {
+		if hold.Key == key {
+			held += hold.Seats
+		}
+	}
+	if capacity-held < seats {
+		return "", ErrSeatsUnavailable
+	}
+
+	reference := make([]byte, 8)
+	_, _ = rand.Read(reference)
+	holdReference := hex.EncodeToString(reference)
+	i.holds[holdReference] = seatHold{Key: key, Seats: seats, ExpiredAt: time.Now().Add(ttl)}
+	return holdReference, nil
+}
+
+func (i *SeatInventory) Release(holdReference string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.purgeExpired()
+
+	if _, exist := i.holds[holdReference]; !exist {
+		return ErrHoldNotFound
+	}
+	delete(i.holds, holdReference)
+	return nil
+}
+
+func (i *SeatInventory) purgeExpired() {
+	now := time.Now()
+	for reference, hold := range i.holds {
+		if now.After(hold.ExpiredAt) {
+			delete(i.holds, reference)
+		}
+	}
+}
@@ -121,50 +121,62 @@ func (g LionAirResponse) Normalize() []internal.Flight {
 			Duration: arrivalTime.Sub(departureTime),
 		}
 
-		class := internal.BusinessClass
-		if flight.Pricing.FareType == "ECONOMY" {
-			class = internal.EconomyClass
-		}
-
 		layover := 0
+		layovers := []internal.Layover{}
 		for _, transit := range flight.Layovers {
 			layover += int(transit.DurationMinutes)
+			layovers = append(layovers, internal.Layover{Airport: transit.Airport, TotalMinute: transit.DurationMinutes})
 		}
 
-		amenities := []string{}
+		amenities := []internal.Amenity{}
 		if flight.Services.WifiAvailable {
-			amenities = append(amenities, "wifi")
+			amenities = append(amenities, internal.AmenityWifi)
 		}
 		if flight.Services.MealsIncluded {
-			amenities = append(amenities, "meal")
+			amenities = append(amenities, internal.AmenityMeal)
 		}
 
+		airline := internal.Airline{Code: mapAirlineCodeToName[LionAirAirline], Name: string(LionAirAirline)}
+		departure := internal.Airport{Airport: flight.Route.From.Code, City: departureCity, Datetime: departureTime.Format(time.RFC3339), Timestamp: departureTime.Unix()}
+		arrival := internal.Airport{Airport: flight.Route.To.Code, City: arrivalCity, Datetime: arrivalTime.Format(time.RFC3339), Timestamp: arrivalTime.Unix()}
+		flightID := flight.ID + "_" + mapAirlineCodeToName[LionAirAirline]
+		price := internal.Price{Amount: flight.Pricing.Total, Currency: flight.Pricing.Currency}
+		baggage := internal.Bag{CarryOn: ParseBaggageAllowance(flight.Services.BaggageAllowance.Cabin), Checked: ParseBaggageAllowance(flight.Services.BaggageAllowance.Hold)}
+		mainFare := BuildFare(flightID, flight.Pricing.FareType, price, flight.SeatsLeft, baggage)
+		flightDuration := internal.Duration{TotalMinute: int16(duration.Duration.Minutes()), Formatted: duration.Format()}
+		aircraft := GetAircraftInfo(flight.PlaneType)
+
 		flights = append(flights, internal.Flight{
-			ID:             flight.ID + "_" + mapAirlineCodeToName[LionAirAirline],
+			ID:             flightID,
 			Provider:       mapAirlineCodeToName[LionAirAirline],
-			Airline:        internal.Airline{Code: mapAirlineCodeToName[LionAirAirline], Name: string(LionAirAirline)},
+			Airline:        airline,
 			FlightNumber:   flight.ID,
-			Departure:      internal.Airport{Airport: flight.Route.From.Code, City: departureCity, Datetime: departureTime.Format(time.RFC3339), Timestamp: departureTime.Unix()},
-			Arrival:        internal.Airport{Airport: flight.Route.To.Code, City: arrivalCity, Datetime: arrivalTime.Format(time.RFC3339), Timestamp: arrivalTime.Unix()},
-			Duration:       internal.Duration{TotalMinute: int16(duration.Duration.Minutes()), Formatted: duration.Format()},
+			Departure:      departure,
+			Arrival:        arrival,
+			Duration:       flightDuration,
 			Stops:          flight.StopCount,
-			Price:          internal.Price{Amount: flight.Pricing.Total, Currency: flight.Pricing.Currency},
+			Price:          price,
 			AvailableSeats: flight.SeatsLeft,
-			CabinClass:     class,
-			Aircraft:       &flight.PlaneType,
+			CabinClass:     mainFare.CabinClass,
+			Aircraft:       aircraft,
 			Amenities:      amenities,
-			Baggage:        internal.Bag{CarryOn: flight.Services.BaggageAllowance.Cabin, Checked: flight.Services.BaggageAllowance.Hold},
+			Baggage:        baggage,
 			Layover:        layover,
+			Layovers:       layovers,
+			Segments:       BuildSegments(airline, flight.ID, departure, arrival, aircraft, flightDuration, layovers),
+			Fares:          []internal.Fare{mainFare},
 		})
 
 	}
 	return flights
 }
 
-type LionAir struct{}
+type LionAir struct {
+	Inventory *SeatInventory
+}
 
 func NewLionAir() *LionAir {
-	return &LionAir{}
+	return &LionAir{Inventory: NewSeatInventory()}
 }
 
 func (b *LionAir) GetFlights(origin, destination, departureDate string) (LionAirResponse, error) {
@@ -181,6 +193,28 @@ func (b *LionAir) GetFlights(origin, destination, departureDate string) (LionAir
 
 	var response LionAirResponse
 	_ = json.Unmarshal([]byte(strLionAirMockResponse), &response)
+	for i, flight := range response.Data.AvailableFlights {
+		response.Data.AvailableFlights[i].SeatsLeft -= b.Inventory.Held(flight.ID, departureDate, "")
+	}
 
 	return response, nil
 }
+
+func (b *LionAir) Hold(request HoldRequest) (string, error) {
+	helper.RandomDelay(200, 400)
+	if !request.isMockRoute() {
+		return "", ErrSeatsUnavailable
+	}
+	var response LionAirResponse
+	_ = json.Unmarshal([]byte(strLionAirMockResponse), &response)
+	for _, flight := range response.Data.AvailableFlights {
+		if flight.ID == request.FlightNumber {
+			return b.Inventory.Hold(request.FlightNumber, request.DepartureDate, request.BookingClass, flight.SeatsLeft, request.Seats, request.TTL)
+		}
+	}
+	return "", ErrSeatsUnavailable
+}
+
+func (b *LionAir) Release(holdReference string) error {
+	return b.Inventory.Release(holdReference)
+}
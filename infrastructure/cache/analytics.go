@@ -0,0 +1,232 @@
+package cache
+
+import (
+	"cmp"
+	"context"
+	"kevinjuniawan/bookcabin/internal"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	analyticsBucketFormat = "2006010215"
+	analyticsResultTTL    = 10 * time.Second // union of buckets is kept shortly for the query only
+)
+
+// analyticsTTL keep buckets a bit longer than the longest window
+var analyticsTTL = 7*24*time.Hour + 2*time.Hour
+
+func analyticsKey(kind string, bucket time.Time) string {
+	return "analytics:" + kind + ":" + bucket.UTC().Format(analyticsBucketFormat)
+}
+
+// RecordSearch count the search in the hour bucket : sorted sets of routes, route dates and zero result routes,
+// a hash of counters and a HyperLogLog of clients
+func (c *CacheService) RecordSearch(ctx context.Context, event internal.SearchEvent) error {
+	bucket := event.SearchedAt
+	cacheField := "cache_miss"
+	if event.IsCache {
+		cacheField = "cache_hit"
+	}
+	_, err := c.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZIncrBy(ctx, analyticsKey("routes", bucket), 1, event.Route())
+		pipe.ZIncrBy(ctx, analyticsKey("dates", bucket), 1, event.Route()+":"+event.DepartureDate)
+		pipe.HIncrBy(ctx, analyticsKey("counters", bucket), "searches", 1)
+		pipe.HIncrBy(ctx, analyticsKey("counters", bucket), cacheField, 1)
+		if event.ResultCount == 0 {
+			pipe.ZIncrBy(ctx, analyticsKey("zero_results", bucket), 1, event.Route())
+			pipe.HIncrBy(ctx, analyticsKey("counters", bucket), "zero_results", 1)
+		}
+		if event.ClientID != "" {
+			pipe.PFAdd(ctx, analyticsKey("clients", bucket), event.ClientID)
+		}
+		for _, kind := range []string{"routes", "dates", "zero_results", "counters", "clients"} {
+			pipe.Expire(ctx, analyticsKey(kind, bucket), analyticsTTL)
+		}
+		return nil
+	})
+	return err
+}
+
+func (c *CacheService) GetAnalytics(ctx context.Context, params internal.GetAnalyticsParams) (internal.AnalyticsReport, error) {
+	to := time.Now()
+	window := internal.AnalyticsWindows[params.Window]
+	buckets := internal.AnalyticsBuckets(to, window)
+	report := internal.AnalyticsReport{Window: params.Window, From: to.Add(-window), To: to}
+
+	var err error
+	if report.TopRoutes, err = c.topAnalytics(ctx, "routes", buckets, params.Limit); err != nil {
+		return internal.AnalyticsReport{}, err
+	}
+	if report.TopDepartureDates, err = c.topAnalytics(ctx, "dates", buckets, params.Limit); err != nil {
+		return internal.AnalyticsReport{}, err
+	}
+	for i, stat := range report.TopDepartureDates {
+		report.TopDepartureDates[i].Route, report.TopDepartureDates[i].DepartureDate, _ = strings.Cut(stat.Route, ":")
+	}
+	if report.TopZeroResultRoutes, err = c.topAnalytics(ctx, "zero_results", buckets, params.Limit); err != nil {
+		return internal.AnalyticsReport{}, err
+	}
+
+	clientKeys := make([]string, len(buckets))
+	counters := make([]*redis.StringStringMapCmd, len(buckets))
+	_, err = c.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, bucket := range buckets {
+			clientKeys[i] = analyticsKey("clients", bucket)
+			counters[i] = pipe.HGetAll(ctx, analyticsKey("counters", bucket))
+		}
+		return nil
+	})
+	if err != nil {
+		return internal.AnalyticsReport{}, err
+	}
+	for _, counter := range counters {
+		values := counter.Val()
+		report.Searches += parseCounter(values["searches"])
+		report.CacheHits += parseCounter(values["cache_hit"])
+		report.CacheMisses += parseCounter(values["cache_miss"])
+		report.ZeroResults += parseCounter(values["zero_results"])
+	}
+	// PFCOUNT of several keys count the union, a client searching every hour is counted once
+	if report.UniqueClients, err = c.Client.PFCount(ctx, clientKeys...).Result(); err != nil {
+		return internal.AnalyticsReport{}, err
+	}
+	return report, nil
+}
+
+// topAnalytics sum the window buckets into a short lived sorted set and return its highest members
+func (c *CacheService) topAnalytics(ctx context.Context, kind string, buckets []time.Time, limit int) ([]internal.RouteStat, error) {
+	keys := make([]string, len(buckets))
+	for i, bucket := range buckets {
+		keys[i] = analyticsKey(kind, bucket)
+	}
+	resultKey := "analytics:result:" + kind + ":" + newSnapshotID()
+	members, err := c.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZUnionStore(ctx, resultKey, &redis.ZStore{Keys: keys})
+		pipe.Expire(ctx, resultKey, analyticsResultTTL)
+		pipe.ZRevRangeWithScores(ctx, resultKey, 0, int64(limit-1))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := []internal.RouteStat{}
+	for _, member := range members[2].(*redis.ZSliceCmd).Val() {
+		route, _ := member.Member.(string)
+		stats = append(stats, internal.RouteStat{Route: route, Count: int64(member.Score)})
+	}
+	return stats, nil
+}
+
+func parseCounter(value string) int64 {
+	counter, _ := strconv.ParseInt(value, 10, 64)
+	return counter
+}
+
+type memoryAnalyticsBucket struct {
+	routes      map[string]int64
+	dates       map[string]int64
+	zeroResults map[string]int64
+	counters    map[string]int64
+	clients     map[string]bool // exact count, no HyperLogLog needed in memory
+}
+
+func (c *MemoryCacheService) RecordSearch(ctx context.Context, event internal.SearchEvent) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	bucketTime := event.SearchedAt.UTC().Truncate(time.Hour)
+	for bucketTime := range c.analytics {
+		if time.Since(bucketTime) > analyticsTTL {
+			delete(c.analytics, bucketTime)
+		}
+	}
+
+	bucket, exist := c.analytics[bucketTime]
+	if !exist {
+		bucket = &memoryAnalyticsBucket{
+			routes:      map[string]int64{},
+			dates:       map[string]int64{},
+			zeroResults: map[string]int64{},
+			counters:    map[string]int64{},
+			clients:     map[string]bool{},
+		}
+		c.analytics[bucketTime] = bucket
+	}
+	bucket.routes[event.Route()]++
+	bucket.dates[event.Route()+":"+event.DepartureDate]++
+	bucket.counters["searches"]++
+	if event.IsCache {
+		bucket.counters["cache_hit"]++
+	} else {
+		bucket.counters["cache_miss"]++
+	}
+	if event.ResultCount == 0 {
+		bucket.zeroResults[event.Route()]++
+		bucket.counters["zero_results"]++
+	}
+	if event.ClientID != "" {
+		bucket.clients[event.ClientID] = true
+	}
+	return nil
+}
+
+func (c *MemoryCacheService) GetAnalytics(ctx context.Context, params internal.GetAnalyticsParams) (internal.AnalyticsReport, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	to := time.Now()
+	window := internal.AnalyticsWindows[params.Window]
+	report := internal.AnalyticsReport{Window: params.Window, From: to.Add(-window), To: to}
+
+	routes, dates, zeroResults := map[string]int64{}, map[string]int64{}, map[string]int64{}
+	clients := map[string]bool{}
+	for _, bucketTime := range internal.AnalyticsBuckets(to, window) {
+		bucket, exist := c.analytics[bucketTime]
+		if !exist {
+			continue
+		}
+		for route, count := range bucket.routes {
+			routes[route] += count
+		}
+		for date, count := range bucket.dates {
+			dates[date] += count
+		}
+		for route, count := range bucket.zeroResults {
+			zeroResults[route] += count
+		}
+		for clientID := range bucket.clients {
+			clients[clientID] = true
+		}
+		report.Searches += bucket.counters["searches"]
+		report.CacheHits += bucket.counters["cache_hit"]
+		report.CacheMisses += bucket.counters["cache_miss"]
+		report.ZeroResults += bucket.counters["zero_results"]
+	}
+	report.UniqueClients = int64(len(clients))
+	report.TopRoutes = topMemoryAnalytics(routes, params.Limit)
+	report.TopDepartureDates = topMemoryAnalytics(dates, params.Limit)
+	for i, stat := range report.TopDepartureDates {
+		report.TopDepartureDates[i].Route, report.TopDepartureDates[i].DepartureDate, _ = strings.Cut(stat.Route, ":")
+	}
+	report.TopZeroResultRoutes = topMemoryAnalytics(zeroResults, params.Limit)
+	return report, nil
+}
+
+// topMemoryAnalytics order like ZREVRANGE, by count then member descending
+func topMemoryAnalytics(counts map[string]int64, limit int) []internal.RouteStat {
+	stats := []internal.RouteStat{}
+	for route, count := range counts {
+		stats = append(stats, internal.RouteStat{Route: route, Count: count})
+	}
+	slices.SortFunc(stats, func(a, b internal.RouteStat) int {
+		if compared := cmp.Compare(b.Count, a.Count); compared != 0 {
+			return compared
+		}
+		return cmp.Compare(b.Route, a.Route)
+	})
+	return stats[:min(limit, len(stats))]
+}
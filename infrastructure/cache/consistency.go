@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"kevinjuniawan/bookcabin/internal"
+	"log"
+	"sync/atomic"
+)
+
+// consistencyCounters count the sorted set members found without their flight blob, per instance since start
+type consistencyCounters struct {
+	danglingMembers  atomic.Int64
+	refetchTriggered atomic.Int64
+}
+
+func (c *consistencyCounters) stats() internal.CacheConsistencyStats {
+	return internal.CacheConsistencyStats{
+		DanglingMembers:  c.danglingMembers.Load(),
+		RefetchTriggered: c.refetchTriggered.Load(),
+	}
+}
+
+func (c *CacheService) ConsistencyStats() internal.CacheConsistencyStats {
+	return c.consistency.stats()
+}
+
+// healDanglingMembers remove members without flight blob from the snapshot index, and drop the route fresh marker
+// when too many are missing so the route is refetched instead of served with holes
+func (c *CacheService) healDanglingMembers(params internal.GetFlightsParams, snapshot string, flightIDs []string, total int) (isRefetch bool) {
+	routeKey := params.RouteKey()
+	c.consistency.danglingMembers.Add(int64(len(flightIDs)))
+	log.Printf("[%s]%d of %d flights are missing in snapshot %s\n", routeKey, len(flightIDs), total, snapshot)
+
+	members := make([]interface{}, len(flightIDs))
+	for i, flightID := range flightIDs {
+		members[i] = flightID
+	}
+	go func() {
+		ctx := context.Background()
+		for _, key := range zsetKeys(params, snapshot) {
+			if err := c.Client.ZRem(ctx, key, members...).Err(); err != nil {
+				log.Printf("[%s]fail to remove dangling members, Err : %v\n", key, err)
+			}
+		}
+	}()
+
+	if float64(len(flightIDs))/float64(total) <= c.Cfg.CacheMaxMissingRatio {
+		return false
+	}
+	c.consistency.refetchTriggered.Add(1)
+	go func() {
+		if err := c.Client.Del(context.Background(), freshKey(routeKey)).Err(); err != nil {
+			log.Printf("[%s]fail to expire route for refetch, Err : %v\n", routeKey, err)
+		}
+	}()
+	return true
+}
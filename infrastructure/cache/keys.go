@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// CacheSchemaVersion is part of every flight key, bump it when a key layout or a cached value format change.
+// Version 1 keyed routes on origin, destination and date only and stored flight blobs under bare flight IDs
+const CacheSchemaVersion = 2
+
+const (
+	schemaVersionKey     = "cache:schema_version"
+	legacySchemaVersion  = 1
+	migrationScanCount   = 500
+	detailSnapshotPrefix = "detail"
+	previousSnapshotTTL  = 30 * time.Second // grace for readers still on the replaced snapshot
+)
+
+var versionTag = fmt.Sprintf("v%d", CacheSchemaVersion)
+
+// legacyFlightProviders are the providers of version 1 flight blobs, only these bare IDs are deleted so other keys with an underscore are kept
+var (
+	legacyFlightProviders  = []string{"AirAsia", "BatikAir", "GarudaIndonesia", "LionAir"}
+	legacyFlightKeyPattern = regexp.MustCompile(`^[A-Z0-9]+_(` + strings.Join(legacyFlightProviders, "|") + `)$`)
+)
+
+// routePrefix is shared by every key of a route : sorted sets, snapshot pointer and fresh marker
+func routePrefix(routeKey string) string {
+	return "flights:" + versionTag + ":" + routeKey
+}
+
+// snapshotPointerKey hold the snapshot currently served for the route
+func snapshotPointerKey(routeKey string) string {
+	return routePrefix(routeKey) + ":snapshot"
+}
+
+func freshKey(routeKey string) string {
+	return routePrefix(routeKey) + ":fresh"
+}
+
+func rebuildLockKey(routeKey string) string {
+	return "lock:" + routePrefix(routeKey)
+}
+
+func fenceKey(routeKey string) string {
+	return "fence:" + routePrefix(routeKey)
+}
+
+// flightBlobKey namespace the flight per route and snapshot, the same flight ID on another date or cabin is another blob
+func flightBlobKey(routeKey, snapshot, flightID string) string {
+	return fmt.Sprintf("flight:%s:%s:%s:%s", versionTag, routeKey, snapshot, flightID)
+}
+
+// detailFlightKey hold a flight fetched by ID outside of any search snapshot
+func detailFlightKey(flightID string) string {
+	return fmt.Sprintf("flight:%s:%s:%s", versionTag, detailSnapshotPrefix, flightID)
+}
+
+// latestFlightKey point to the blob of the last snapshot containing the flight, used for lookup by flight ID
+// flight IDs are scoped by route so the pointer never cross to another date or cabin
+func latestFlightKey(flightID string) string {
+	return fmt.Sprintf("flight_latest:%s:%s", versionTag, flightID)
+}
+
+func newSnapshotID() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// MigrateSchema delete keys of older schema versions once, the first replica running a newer version does it
+// and a replica running an older version never delete newer keys
+func (c *CacheService) MigrateSchema(ctx context.Context) error {
+	storedVersion, err := c.Client.Get(ctx, schemaVersionKey).Int()
+	if err == redis.Nil {
+		storedVersion = legacySchemaVersion
+	} else if err != nil {
+		return err
+	}
+	if storedVersion >= CacheSchemaVersion {
+		return nil
+	}
+
+	log.Printf("migrate cache schema from v%d to v%d\n", storedVersion, CacheSchemaVersion)
+	deleted := 0
+	patterns := []string{"flights:*", "flight:*", "flight_latest:*", "lock:flights:*", "fence:flights:*"}
+	for _, provider := range legacyFlightProviders {
+		patterns = append(patterns, "*_"+provider)
+	}
+	for _, pattern := range patterns {
+		count, err := c.deleteOutdatedKeys(ctx, pattern)
+		if err != nil {
+			return err
+		}
+		deleted += count
+	}
+	log.Printf("cache schema migrated to v%d, %d outdated keys deleted\n", CacheSchemaVersion, deleted)
+	return c.Client.Set(ctx, schemaVersionKey, CacheSchemaVersion, 0).Err()
+}
+
+func (c *CacheService) deleteOutdatedKeys(ctx context.Context, pattern string) (int, error) {
+	deleted := 0
+	iter := c.Client.Scan(ctx, 0, pattern, migrationScanCount).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		if !isOutdatedKey(key) {
+			continue
+		}
+		if err := c.Client.Unlink(ctx, key).Err(); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, iter.Err()
+}
+
+// isOutdatedKey match flight keys without the current version, and version 1 flight blobs which are bare IDs like GA400_GarudaIndonesia
+func isOutdatedKey(key string) bool {
+	if !strings.Contains(key, ":") {
+		return legacyFlightKeyPattern.MatchString(key)
+	}
+	if !strings.HasPrefix(key, "flights:") && !strings.HasPrefix(key, "flight:") && !strings.HasPrefix(key, "flight_latest:") &&
+		!strings.HasPrefix(key, "lock:flights:") && !strings.HasPrefix(key, "fence:flights:") {
+		return false
+	}
+	return !strings.Contains(key, ":"+versionTag+":")
+}
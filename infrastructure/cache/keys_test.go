@@ -0,0 +1,33 @@
+package cache
+
+import "testing"
+
+func TestIsOutdatedKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{key: "GA400_GarudaIndonesia", want: true},
+		{key: "ID6514_BatikAir", want: true},
+		{key: "flights:CGK:DPS:2025-12-15:price", want: true},
+		{key: "flight:v1:CGK-DPS:abc:GA400_GarudaIndonesia", want: true},
+		{key: "flight_latest:v1:GA400_GarudaIndonesia", want: true},
+		{key: "price_rules", want: false},
+		{key: "schema_version", want: false},
+		{key: "GA400_Garuda", want: false},
+		{key: "ga400_GarudaIndonesia", want: false},
+		{key: "api_key:abc", want: false},
+		{key: "rate_limit:default:ip:1.1.1.1", want: false},
+		{key: snapshotPointerKey("CGK-DPS"), want: false},
+		{key: flightBlobKey("CGK-DPS", "abc", "GA400_GarudaIndonesia"), want: false},
+		{key: latestFlightKey("GA400_GarudaIndonesia"), want: false},
+		{key: rebuildLockKey("CGK-DPS"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := isOutdatedKey(tt.key); got != tt.want {
+				t.Errorf("isOutdatedKey(%s) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"kevinjuniawan/bookcabin/internal"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const localLimiterSweepInterval = time.Minute
+
+// sliding window log, the request timestamps of the window are kept in a sorted set
+// time is read from Redis so replicas with clock skew share the same window
+var slidingWindowScript = redis.NewScript(`
+local now = redis.call('TIME')
+local nowMs = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000)
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', nowMs - window)
+local count = redis.call('ZCARD', KEYS[1])
+if count < limit then
+	redis.call('ZADD', KEYS[1], nowMs, ARGV[3])
+	redis.call('PEXPIRE', KEYS[1], window)
+	return {1, limit - count - 1, 0}
+end
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+return {0, 0, tonumber(oldest[2]) + window - nowMs}
+`)
+
+func rateLimitKey(clientID string, tier internal.RateLimitTier) string {
+	return fmt.Sprintf("rate_limit:%s:%s", tier.Name, clientID)
+}
+
+// rateLimitMember is unique per request, requests in the same millisecond are still counted apart
+func rateLimitMember() string {
+	return newSnapshotID() + "-" + strconv.FormatInt(rand.Int63(), 36)
+}
+
+// localLimiter is the same sliding window log kept in process memory
+type localLimiter struct {
+	mu        sync.Mutex
+	clients   map[string]localWindow
+	lastSweep time.Time
+}
+
+type localWindow struct {
+	window   time.Duration
+	requests []time.Time
+}
+
+func newLocalLimiter() *localLimiter {
+	return &localLimiter{clients: map[string]localWindow{}, lastSweep: time.Now()}
+}
+
+func (l *localLimiter) allow(clientID string, tier internal.RateLimitTier) internal.RateLimitResult {
+	if tier.Limit <= 0 {
+		return internal.RateLimitResult{Allowed: true}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.sweep(now)
+
+	key := rateLimitKey(clientID, tier)
+	requests := inWindow(l.clients[key].requests, now, tier.Window)
+	if int64(len(requests)) >= tier.Limit {
+		l.clients[key] = localWindow{window: tier.Window, requests: requests}
+		return internal.RateLimitResult{Limit: tier.Limit, RetryAfter: requests[0].Add(tier.Window).Sub(now)}
+	}
+	l.clients[key] = localWindow{window: tier.Window, requests: append(requests, now)}
+	return internal.RateLimitResult{Allowed: true, Limit: tier.Limit, Remaining: tier.Limit - int64(len(requests)) - 1}
+}
+
+// sweep drop clients without request in the window so idle clients do not stay in memory
+func (l *localLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < localLimiterSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, client := range l.clients {
+		if len(client.requests) == 0 || now.Sub(client.requests[len(client.requests)-1]) >= client.window {
+			delete(l.clients, key)
+		}
+	}
+}
+
+func inWindow(requests []time.Time, now time.Time, window time.Duration) []time.Time {
+	for i, requestedAt := range requests {
+		if now.Sub(requestedAt) < window {
+			return requests[i:]
+		}
+	}
+	return requests[:0]
+}
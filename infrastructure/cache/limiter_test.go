@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"kevinjuniawan/bookcabin/internal"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type rateLimiter interface {
+	AllowRequest(ctx context.Context, clientID string, tier internal.RateLimitTier) internal.RateLimitResult
+}
+
+type rateLimitStep struct {
+	clientID      string
+	tier          internal.RateLimitTier
+	sleep         time.Duration
+	wantAllowed   bool
+	wantRemaining int64
+}
+
+var (
+	testTier      = internal.RateLimitTier{Name: "test", Limit: 2, Window: 300 * time.Millisecond}
+	testOtherTier = internal.RateLimitTier{Name: "other", Limit: 1, Window: 300 * time.Millisecond}
+)
+
+var rateLimitTests = []struct {
+	name  string
+	steps []rateLimitStep
+}{
+	{
+		name: "reject above the limit",
+		steps: []rateLimitStep{
+			{clientID: "a", tier: testTier, wantAllowed: true, wantRemaining: 1},
+			{clientID: "a", tier: testTier, wantAllowed: true, wantRemaining: 0},
+			{clientID: "a", tier: testTier, wantAllowed: false},
+		},
+	},
+	{
+		name: "clients and tiers have their own window",
+		steps: []rateLimitStep{
+			{clientID: "a", tier: testTier, wantAllowed: true, wantRemaining: 1},
+			{clientID: "a", tier: testTier, wantAllowed: true, wantRemaining: 0},
+			{clientID: "b", tier: testTier, wantAllowed: true, wantRemaining: 1},
+			{clientID: "a", tier: testOtherTier, wantAllowed: true, wantRemaining: 0},
+			{clientID: "a", tier: testOtherTier, wantAllowed: false},
+		},
+	},
+	{
+		name: "window slide",
+		steps: []rateLimitStep{
+			{clientID: "a", tier: testTier, wantAllowed: true, wantRemaining: 1},
+			{clientID: "a", tier: testTier, sleep: 200 * time.Millisecond, wantAllowed: true, wantRemaining: 0},
+			{clientID: "a", tier: testTier, wantAllowed: false},
+			{clientID: "a", tier: testTier, sleep: 150 * time.Millisecond, wantAllowed: true, wantRemaining: 0},
+			{clientID: "a", tier: testTier, wantAllowed: false},
+		},
+	},
+	{
+		name: "limit 0 is unlimited",
+		steps: []rateLimitStep{
+			{clientID: "a", tier: internal.RateLimitTier{Name: "unlimited", Window: time.Second}, wantAllowed: true},
+			{clientID: "a", tier: internal.RateLimitTier{Name: "unlimited", Window: time.Second}, wantAllowed: true},
+		},
+	},
+}
+
+func runRateLimitTests(t *testing.T, newLimiter func() rateLimiter) {
+	for _, tt := range rateLimitTests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := newLimiter()
+			prefix := strconv.FormatInt(time.Now().UnixNano(), 36) + ":"
+			for i, step := range tt.steps {
+				time.Sleep(step.sleep)
+				result := limiter.AllowRequest(context.Background(), prefix+step.clientID, step.tier)
+				if result.Allowed != step.wantAllowed {
+					t.Fatalf("step %d allowed = %v, want %v", i, result.Allowed, step.wantAllowed)
+				}
+				if step.wantAllowed && result.Remaining != step.wantRemaining {
+					t.Errorf("step %d remaining = %d, want %d", i, result.Remaining, step.wantRemaining)
+				}
+				if !step.wantAllowed && (result.RetryAfter <= 0 || result.RetryAfter > step.tier.Window) {
+					t.Errorf("step %d retry after = %v, want within %v", i, result.RetryAfter, step.tier.Window)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryAllowRequest(t *testing.T) {
+	runRateLimitTests(t, func() rateLimiter {
+		return &MemoryCacheService{limiter: newLocalLimiter()}
+	})
+}
+
+// TestRedisAllowRequest run the Lua script, it need a Redis at REDIS_TEST_ADDR
+func TestRedisAllowRequest(t *testing.T) {
+	addr := os.Getenv("REDIS_TEST_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_TEST_ADDR is not set")
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Fatalf("fail to ping redis, Err : %v", err)
+	}
+	// a failing script would be hidden by the local limiter fallback
+	key := rateLimitKey("script-check", testTier)
+	if err := slidingWindowScript.Run(context.Background(), client, []string{key}, testTier.Window.Milliseconds(), testTier.Limit, rateLimitMember()).Err(); err != nil {
+		t.Fatalf("fail to run rate limiter script, Err : %v", err)
+	}
+	runRateLimitTests(t, func() rateLimiter {
+		return &CacheService{Client: client, limiter: newLocalLimiter()}
+	})
+}
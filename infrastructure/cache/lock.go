@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"kevinjuniawan/bookcabin/internal"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	rebuildLockPollInterval = 100 * time.Millisecond
+	fenceTTL                = 24 * time.Hour
+)
+
+var errRebuildFenced = errors.New("rebuild lock is taken by newer holder")
+
+// acquire the lock and issue a new fencing token in one step, token is only increased when the lock is taken
+var acquireRebuildLockScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+local token = redis.call('INCR', KEYS[2])
+redis.call('PEXPIRE', KEYS[2], ARGV[2])
+redis.call('SET', KEYS[1], token, 'PX', ARGV[1])
+return token
+`)
+
+var releaseRebuildLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// rebuildLocks keep the fencing token of the route locks taken by this instance
+type rebuildLocks struct {
+	mu     sync.Mutex
+	tokens map[string]rebuildLock
+}
+
+type rebuildLock struct {
+	token      int64
+	acquiredAt time.Time
+}
+
+func newRebuildLocks() *rebuildLocks {
+	return &rebuildLocks{tokens: map[string]rebuildLock{}}
+}
+
+func (l *rebuildLocks) set(routeKey string, token int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens[routeKey] = rebuildLock{token: token, acquiredAt: time.Now()}
+}
+
+func (l *rebuildLocks) isHeld(routeKey string, ttl time.Duration) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lock, exist := l.tokens[routeKey]
+	return exist && time.Since(lock.acquiredAt) < ttl
+}
+
+func (l *rebuildLocks) take(routeKey string) (int64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lock, exist := l.tokens[routeKey]
+	delete(l.tokens, routeKey)
+	return lock.token, exist
+}
+
+func (c *CacheService) acquireRebuildLock(ctx context.Context, routeKey string) (bool, error) {
+	token, err := acquireRebuildLockScript.Run(ctx, c.Client, []string{rebuildLockKey(routeKey), fenceKey(routeKey)},
+		c.Cfg.RebuildLockTTL.Milliseconds(), fenceTTL.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+	if token == 0 {
+		return false, nil
+	}
+	c.rebuildLocks.set(routeKey, token)
+	return true, nil
+}
+
+func (c *CacheService) releaseRebuildLock(ctx context.Context, routeKey string, token int64) error {
+	return releaseRebuildLockScript.Run(ctx, c.Client, []string{rebuildLockKey(routeKey)}, token).Err()
+}
+
+// AcquireRebuildLock take the route lock for a rebuild outside of a search, e.g. cache warming, false when the route is
+// already being rebuilt by this instance or another replica. The lock is released by SetFlights or ReleaseRebuildLock
+func (c *CacheService) AcquireRebuildLock(ctx context.Context, params internal.GetFlightsParams) (bool, error) {
+	routeKey := params.RouteKey()
+	if c.rebuildLocks.isHeld(routeKey, c.Cfg.RebuildLockTTL) {
+		return false, nil
+	}
+	return c.acquireRebuildLock(ctx, routeKey)
+}
+
+// ReleaseRebuildLock give up the route lock taken by GetSortedFlightsByParams when the rebuild does not write the cache,
+// e.g. the provider fetch failed, so another replica can rebuild the route before the lock expire
+func (c *CacheService) ReleaseRebuildLock(ctx context.Context, params internal.GetFlightsParams) error {
+	routeKey := params.RouteKey()
+	token, isLocked := c.rebuildLocks.take(routeKey)
+	if !isLocked {
+		return nil
+	}
+	return c.releaseRebuildLock(ctx, routeKey, token)
+}
+
+// isFenced is true when another holder took the route lock after the token was issued, the older rebuild must not write
+func (c *CacheService) isFenced(ctx context.Context, client redis.Cmdable, routeKey string, token int64) (bool, error) {
+	latestToken, err := client.Get(ctx, fenceKey(routeKey)).Int64()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return latestToken != token, nil
+}
+
+// waitForRebuild poll until the lock holder publish fresh flights, the lock is released or expired, or the wait is over
+func (c *CacheService) waitForRebuild(ctx context.Context, routeKey string) (isFresh bool, err error) {
+	deadline := time.Now().Add(c.Cfg.RebuildLockWait)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(rebuildLockPollInterval):
+		}
+
+		fresh, err := c.Client.Exists(ctx, freshKey(routeKey)).Result()
+		if err != nil {
+			return false, err
+		}
+		if fresh == 1 {
+			return true, nil
+		}
+		locked, err := c.Client.Exists(ctx, rebuildLockKey(routeKey)).Result()
+		if err != nil {
+			return false, err
+		}
+		if locked == 0 {
+			return false, nil // holder gave up without writing
+		}
+	}
+	return false, nil
+}
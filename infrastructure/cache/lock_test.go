@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"kevinjuniawan/bookcabin/config"
+	"kevinjuniawan/bookcabin/internal"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestRebuildLocks(t *testing.T) {
+	tests := []struct {
+		name       string
+		setToken   int64
+		age        time.Duration
+		ttl        time.Duration
+		wantHeld   bool
+		wantToken  int64
+		wantLocked bool
+	}{
+		{name: "not taken", ttl: time.Second},
+		{name: "held", setToken: 3, ttl: time.Second, wantHeld: true, wantToken: 3, wantLocked: true},
+		{name: "expired is not held but still released", setToken: 4, age: 2 * time.Second, ttl: time.Second, wantToken: 4, wantLocked: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locks := newRebuildLocks()
+			if tt.setToken != 0 {
+				locks.set("route", tt.setToken)
+				lock := locks.tokens["route"]
+				lock.acquiredAt = lock.acquiredAt.Add(-tt.age)
+				locks.tokens["route"] = lock
+			}
+			if held := locks.isHeld("route", tt.ttl); held != tt.wantHeld {
+				t.Errorf("isHeld() = %v, want %v", held, tt.wantHeld)
+			}
+			token, locked := locks.take("route")
+			if token != tt.wantToken || locked != tt.wantLocked {
+				t.Errorf("take() = %d %v, want %d %v", token, locked, tt.wantToken, tt.wantLocked)
+			}
+			if _, locked := locks.take("route"); locked {
+				t.Errorf("take() twice, lock must be removed")
+			}
+		})
+	}
+}
+
+// TestRedisRebuildLock need a Redis at REDIS_TEST_ADDR
+func TestRedisRebuildLock(t *testing.T) {
+	addr := os.Getenv("REDIS_TEST_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_TEST_ADDR is not set")
+	}
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Fatalf("fail to ping redis, Err : %v", err)
+	}
+	cfg := &config.Config{RebuildLockTTL: time.Second}
+	newReplica := func() *CacheService {
+		return &CacheService{Client: client, Cfg: cfg, rebuildLocks: newRebuildLocks()}
+	}
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T, params internal.GetFlightsParams)
+	}{
+		{
+			name: "second replica wait for the holder",
+			run: func(t *testing.T, params internal.GetFlightsParams) {
+				routeKey := params.RouteKey()
+				holder, other := newReplica(), newReplica()
+				if acquired, err := holder.acquireRebuildLock(ctx, routeKey); err != nil || !acquired {
+					t.Fatalf("acquireRebuildLock() = %v, %v, want acquired", acquired, err)
+				}
+				if acquired, err := other.acquireRebuildLock(ctx, routeKey); err != nil || acquired {
+					t.Fatalf("acquireRebuildLock() = %v, %v, want not acquired", acquired, err)
+				}
+			},
+		},
+		{
+			name: "released lock is taken by another replica",
+			run: func(t *testing.T, params internal.GetFlightsParams) {
+				routeKey := params.RouteKey()
+				holder, other := newReplica(), newReplica()
+				holder.acquireRebuildLock(ctx, routeKey)
+				if err := holder.ReleaseRebuildLock(ctx, params); err != nil {
+					t.Fatalf("ReleaseRebuildLock() Err : %v", err)
+				}
+				if acquired, err := other.acquireRebuildLock(ctx, routeKey); err != nil || !acquired {
+					t.Fatalf("acquireRebuildLock() = %v, %v, want acquired after release", acquired, err)
+				}
+			},
+		},
+		{
+			name: "holder whose lock expired is fenced",
+			run: func(t *testing.T, params internal.GetFlightsParams) {
+				routeKey := params.RouteKey()
+				holder, other := newReplica(), newReplica()
+				holder.acquireRebuildLock(ctx, routeKey)
+				client.Del(ctx, rebuildLockKey(routeKey)) // lock expired
+				other.acquireRebuildLock(ctx, routeKey)
+
+				oldToken, _ := holder.rebuildLocks.take(routeKey)
+				newToken, _ := other.rebuildLocks.take(routeKey)
+				if fenced, err := holder.isFenced(ctx, client, routeKey, oldToken); err != nil || !fenced {
+					t.Errorf("isFenced() old token = %v, %v, want fenced", fenced, err)
+				}
+				if fenced, err := other.isFenced(ctx, client, routeKey, newToken); err != nil || fenced {
+					t.Errorf("isFenced() new token = %v, %v, want not fenced", fenced, err)
+				}
+				// the old holder release must not drop the newer lock
+				holder.releaseRebuildLock(ctx, routeKey, oldToken)
+				if locked, _ := client.Exists(ctx, rebuildLockKey(routeKey)).Result(); locked != 1 {
+					t.Errorf("newer lock is released by the old holder")
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := internal.GetFlightsParams{Origin: "TST", Destination: "LCK", DepartureDate: time.Now().Format(time.RFC3339Nano), CabinClass: tt.name}
+			routeKey := params.RouteKey()
+			defer client.Del(ctx, rebuildLockKey(routeKey), fenceKey(routeKey))
+			tt.run(t, params)
+		})
+	}
+}
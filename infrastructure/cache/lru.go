@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"container/list"
+	"kevinjuniawan/bookcabin/internal"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type lruEntry struct {
+	key       string
+	flights   []internal.Flight
+	expiredAt time.Time
+}
+
+// flightLRU is bounded by both entry count and total cached flights, least recently used entries are evicted first
+type flightLRU struct {
+	mu          sync.Mutex
+	maxEntries  int
+	maxFlights  int
+	flightCount int
+	items       map[string]*list.Element
+	order       *list.List
+}
+
+func newFlightLRU(maxEntries, maxFlights int) *flightLRU {
+	return &flightLRU{
+		maxEntries: maxEntries,
+		maxFlights: maxFlights,
+		items:      map[string]*list.Element{},
+		order:      list.New(),
+	}
+}
+
+func (l *flightLRU) get(key string) ([]internal.Flight, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	element, exist := l.items[key]
+	if !exist {
+		return nil, false
+	}
+	entry := element.Value.(*lruEntry)
+	if time.Now().After(entry.expiredAt) {
+		l.removeElement(element)
+		return nil, false
+	}
+	l.order.MoveToFront(element)
+	return cloneFlights(entry.flights), true
+}
+
+func (l *flightLRU) set(key string, flights []internal.Flight, ttl time.Duration) {
+	if len(flights) > l.maxFlights {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if element, exist := l.items[key]; exist {
+		l.removeElement(element)
+	}
+	element := l.order.PushFront(&lruEntry{key: key, flights: cloneFlights(flights), expiredAt: time.Now().Add(ttl)})
+	l.items[key] = element
+	l.flightCount += len(flights)
+	for l.order.Len() > l.maxEntries || l.flightCount > l.maxFlights {
+		l.removeElement(l.order.Back())
+	}
+}
+
+func (l *flightLRU) removePrefix(prefix string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, element := range l.items {
+		if strings.HasPrefix(key, prefix) {
+			l.removeElement(element)
+		}
+	}
+}
+
+// removeContaining drop entries with the key prefix containing part
+func (l *flightLRU) removeContaining(prefix, part string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, element := range l.items {
+		if strings.HasPrefix(key, prefix) && strings.Contains(key[len(prefix):], part) {
+			l.removeElement(element)
+		}
+	}
+}
+
+// removeFlight drop every entry containing the flight, both flight detail and route lists
+func (l *flightLRU) removeFlight(flightID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, element := range l.items {
+		entry := element.Value.(*lruEntry)
+		if slices.ContainsFunc(entry.flights, func(flight internal.Flight) bool { return flight.ID == flightID }) {
+			l.removeElement(element)
+		}
+	}
+}
+
+// clear drop every entry, used when invalidations may have been missed
+func (l *flightLRU) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.items = map[string]*list.Element{}
+	l.order.Init()
+	l.flightCount = 0
+}
+
+func (l *flightLRU) removeElement(element *list.Element) {
+	entry := element.Value.(*lruEntry)
+	l.order.Remove(element)
+	delete(l.items, entry.key)
+	l.flightCount -= len(entry.flights)
+}
+
+// cloneFlights copy the slices a caller may modify so the cached entry is never shared
+func cloneFlights(flights []internal.Flight) []internal.Flight {
+	cloned := make([]internal.Flight, len(flights))
+	for i, flight := range flights {
+		cloned[i] = flight.Clone()
+	}
+	return cloned
+}
@@ -0,0 +1,321 @@
+package cache
+
+import (
+	"cmp"
+	"context"
+	"kevinjuniawan/bookcabin/config"
+	"kevinjuniawan/bookcabin/internal"
+	"slices"
+	"sync"
+	"time"
+)
+
+type memoryItem[T any] struct {
+	value     T
+	expiredAt time.Time // zero never expire
+}
+
+func (i memoryItem[T]) isExpired() bool {
+	return !i.expiredAt.IsZero() && time.Now().After(i.expiredAt)
+}
+
+func expiredAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+type memorySnapshot struct {
+	snapshot  string
+	flightIDs []string
+}
+
+// MemoryCacheService keep everything in process memory, it is meant for development and CI running without Redis
+type MemoryCacheService struct {
+	Scorer *internal.Scorer
+	Cfg    *config.Config
+
+	mu          sync.Mutex
+	flights     map[string]memoryItem[internal.Flight] // same blob keys as Redis
+	routes      map[string]memoryItem[memorySnapshot]
+	latest      map[string]memoryItem[string] // flight ID to blob key
+	bookings    map[string]memoryItem[internal.Booking]
+	profiles    map[string]internal.TravellerProfile
+	limiter     *localLimiter
+	consistency *consistencyCounters
+	warmingRun  *internal.WarmingRun
+	analytics   map[time.Time]*memoryAnalyticsBucket
+	tenants     map[string]internal.Tenant
+	apiKeys     map[string]internal.APIKey // by key hash
+	apiKeyIDs   map[string]string          // key ID to key hash
+	priceRules  []internal.PriceRule       // nil until saved from the admin api
+}
+
+func NewMemoryCacheService(cfg *config.Config) *MemoryCacheService {
+	return &MemoryCacheService{
+		Scorer:      internal.NewScorer(*cfg),
+		Cfg:         cfg,
+		flights:     map[string]memoryItem[internal.Flight]{},
+		routes:      map[string]memoryItem[memorySnapshot]{},
+		latest:      map[string]memoryItem[string]{},
+		bookings:    map[string]memoryItem[internal.Booking]{},
+		profiles:    map[string]internal.TravellerProfile{},
+		limiter:     newLocalLimiter(),
+		consistency: &consistencyCounters{},
+		analytics:   map[time.Time]*memoryAnalyticsBucket{},
+		tenants:     map[string]internal.Tenant{},
+		apiKeys:     map[string]internal.APIKey{},
+		apiKeyIDs:   map[string]string{},
+	}
+}
+
+// GetSortedFlightsByParams order flights like the Redis sorted sets, by score then flight ID
+func (c *MemoryCacheService) GetSortedFlightsByParams(ctx context.Context, params internal.GetFlightsParams) ([]internal.Flight, error) {
+	_, isAscending := makeKey(params)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	routeKey := params.RouteKey()
+	route, exist := c.routes[routeKey]
+	if !exist || route.isExpired() {
+		return nil, internal.ErrCacheMiss
+	}
+
+	flights := []internal.Flight{}
+	for _, flightID := range route.value.flightIDs {
+		flight, exist := c.flights[flightBlobKey(routeKey, route.value.snapshot, flightID)]
+		if !exist || flight.isExpired() {
+			c.consistency.danglingMembers.Add(1)
+			continue
+		}
+		flights = append(flights, flight.value)
+	}
+	if len(flights) == 0 {
+		return nil, internal.ErrCacheMiss
+	}
+
+	slices.SortFunc(flights, func(a, b internal.Flight) int {
+		if compared := cmp.Compare(c.score(a, params.SortType), c.score(b, params.SortType)); compared != 0 {
+			return compared
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	if !isAscending {
+		slices.Reverse(flights)
+	}
+	return cloneFlights(flights), nil
+}
+
+func (c *MemoryCacheService) score(flight internal.Flight, sortType internal.SortType) float64 {
+	switch sortType {
+	case internal.SortLowestPriceType, internal.SortHighestPriceType:
+		return float64(flight.Price.AmountInIDR())
+	case internal.SortShortestDurationType, internal.SortLongestDurationType:
+		return float64(flight.Duration.TotalMinute)
+	case internal.SortDepartureType:
+		return float64(flight.Departure.Timestamp)
+	case internal.SortArrivalType:
+		return float64(flight.Arrival.Timestamp)
+	}
+	return c.Scorer.ScoreWithDefaultProfile(flight).Total
+}
+
+func (c *MemoryCacheService) GetSortedFlightsByPrice(ctx context.Context, origin, destination, departureDate string, isAscending bool) ([]internal.Flight, error) {
+	sortType := internal.SortLowestPriceType
+	if !isAscending {
+		sortType = internal.SortHighestPriceType
+	}
+	return c.GetSortedFlightsByParams(ctx, internal.GetFlightsParams{Origin: origin, Destination: destination, DepartureDate: departureDate, SortType: sortType})
+}
+
+func (c *MemoryCacheService) GetSortedFlightsByDuration(ctx context.Context, origin, destination, departureDate string, isAscending bool) ([]internal.Flight, error) {
+	sortType := internal.SortShortestDurationType
+	if !isAscending {
+		sortType = internal.SortLongestDurationType
+	}
+	return c.GetSortedFlightsByParams(ctx, internal.GetFlightsParams{Origin: origin, Destination: destination, DepartureDate: departureDate, SortType: sortType})
+}
+
+func (c *MemoryCacheService) GetSortedFlightsByDepartureTime(ctx context.Context, origin, destination, departureDate string, isAscending bool) ([]internal.Flight, error) {
+	return c.GetSortedFlightsByParams(ctx, internal.GetFlightsParams{Origin: origin, Destination: destination, DepartureDate: departureDate, SortType: internal.SortDepartureType})
+}
+
+func (c *MemoryCacheService) GetSortedFlightsByArrivalTime(ctx context.Context, origin, destination, departureDate string, isAscending bool) ([]internal.Flight, error) {
+	return c.GetSortedFlightsByParams(ctx, internal.GetFlightsParams{Origin: origin, Destination: destination, DepartureDate: departureDate, SortType: internal.SortArrivalType})
+}
+
+func (c *MemoryCacheService) GetSortedFlightsByBestValue(ctx context.Context, origin, destination, departureDate string, isAscending bool) ([]internal.Flight, error) {
+	return c.GetSortedFlightsByParams(ctx, internal.GetFlightsParams{Origin: origin, Destination: destination, DepartureDate: departureDate, SortType: internal.SortBestValueType})
+}
+
+func (c *MemoryCacheService) SetFlights(ctx context.Context, flights []internal.Flight, params internal.GetFlightsParams, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = c.Cfg.CacheTTL
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.purgeExpired()
+
+	routeKey := params.RouteKey()
+	snapshot := newSnapshotID()
+	flightIDs := make([]string, len(flights))
+	for i, flight := range cloneFlights(flights) {
+		blobKey := flightBlobKey(routeKey, snapshot, flight.ID)
+		c.flights[blobKey] = memoryItem[internal.Flight]{value: flight, expiredAt: expiredAt(ttl)}
+		c.latest[flight.ID] = memoryItem[string]{value: blobKey, expiredAt: expiredAt(ttl)}
+		flightIDs[i] = flight.ID
+	}
+	c.routes[routeKey] = memoryItem[memorySnapshot]{value: memorySnapshot{snapshot: snapshot, flightIDs: flightIDs}, expiredAt: expiredAt(ttl)}
+	return nil
+}
+
+// AcquireRebuildLock always succeed, routes are not locked within a single instance
+func (c *MemoryCacheService) AcquireRebuildLock(ctx context.Context, params internal.GetFlightsParams) (bool, error) {
+	return true, nil
+}
+
+// ReleaseRebuildLock do nothing, routes are not locked within a single instance
+func (c *MemoryCacheService) ReleaseRebuildLock(ctx context.Context, params internal.GetFlightsParams) error {
+	return nil
+}
+
+func (c *MemoryCacheService) GetFlightByID(ctx context.Context, flightID string) (internal.Flight, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	blobKey, exist := c.latest[flightID]
+	if !exist || blobKey.isExpired() {
+		return internal.Flight{}, internal.ErrFlightNotFound
+	}
+	flight, exist := c.flights[blobKey.value]
+	if !exist || flight.isExpired() {
+		return internal.Flight{}, internal.ErrFlightNotFound
+	}
+	return cloneFlights([]internal.Flight{flight.value})[0], nil
+}
+
+// UpdateFlight replace the cached flight and keep its remaining TTL
+func (c *MemoryCacheService) UpdateFlight(ctx context.Context, flight internal.Flight) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	blobKey, exist := c.latest[flight.ID]
+	if !exist || blobKey.isExpired() {
+		// flight is not part of any snapshot, keep it for the detail lookup only
+		blobKey = memoryItem[string]{value: detailFlightKey(flight.ID), expiredAt: expiredAt(c.Cfg.CacheTTL)}
+		c.latest[flight.ID] = blobKey
+	}
+	c.flights[blobKey.value] = memoryItem[internal.Flight]{value: cloneFlights([]internal.Flight{flight})[0], expiredAt: blobKey.expiredAt}
+	return nil
+}
+
+func (c *MemoryCacheService) CreateBooking(ctx context.Context, booking internal.Booking, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if item, exist := c.bookings[booking.Locator]; exist && !item.isExpired() {
+		return internal.ErrBookingExists
+	}
+	c.bookings[booking.Locator] = memoryItem[internal.Booking]{value: booking, expiredAt: expiredAt(ttl)}
+	return nil
+}
+
+func (c *MemoryCacheService) UpdateBooking(ctx context.Context, booking internal.Booking) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, exist := c.bookings[booking.Locator]
+	if !exist || item.isExpired() {
+		return internal.ErrBookingNotFound
+	}
+	item.value = booking
+	c.bookings[booking.Locator] = item
+	return nil
+}
+
+func (c *MemoryCacheService) GetBooking(ctx context.Context, locator string) (internal.Booking, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, exist := c.bookings[locator]
+	if !exist || item.isExpired() {
+		return internal.Booking{}, internal.ErrBookingNotFound
+	}
+	return item.value, nil
+}
+
+func (c *MemoryCacheService) GetTravellerProfile(ctx context.Context, tenantID, profileID string) (internal.TravellerProfile, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	profile, exist := c.profiles[travellerProfileKey(tenantID, profileID)]
+	if !exist {
+		return internal.TravellerProfile{}, internal.ErrTravellerProfileNotFound
+	}
+	return profile, nil
+}
+
+func (c *MemoryCacheService) SetTravellerProfile(ctx context.Context, profile internal.TravellerProfile) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.profiles[travellerProfileKey(profile.TenantID, profile.ID)] = profile
+	return nil
+}
+
+func (c *MemoryCacheService) AllowRequest(ctx context.Context, clientID string, tier internal.RateLimitTier) internal.RateLimitResult {
+	return c.limiter.allow(clientID, tier)
+}
+
+func (c *MemoryCacheService) IsFresh(ctx context.Context, params internal.GetFlightsParams) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	route, exist := c.routes[params.RouteKey()]
+	return exist && !route.isExpired(), nil
+}
+
+// GetWarmingRoutes has no operator managed routes, only WARMING_ROUTES is used
+func (c *MemoryCacheService) GetWarmingRoutes(ctx context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func (c *MemoryCacheService) SaveWarmingRun(ctx context.Context, run internal.WarmingRun) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.warmingRun = &run
+	return nil
+}
+
+func (c *MemoryCacheService) GetWarmingRun(ctx context.Context) (internal.WarmingRun, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.warmingRun == nil {
+		return internal.WarmingRun{}, internal.ErrWarmingRunNotFound
+	}
+	return *c.warmingRun, nil
+}
+
+func (c *MemoryCacheService) ConsistencyStats() internal.CacheConsistencyStats {
+	return c.consistency.stats()
+}
+
+func (c *MemoryCacheService) Ping(ctx context.Context) error {
+	return nil
+}
+
+// purgeExpired drop expired flights and routes, called on write so memory does not grow with old searches
+func (c *MemoryCacheService) purgeExpired() {
+	for flightID, flight := range c.flights {
+		if flight.isExpired() {
+			delete(c.flights, flightID)
+		}
+	}
+	for flightID, blobKey := range c.latest {
+		if blobKey.isExpired() {
+			delete(c.latest, flightID)
+		}
+	}
+	for routeKey, route := range c.routes {
+		if route.isExpired() {
+			delete(c.routes, routeKey)
+		}
+	}
+	for locator, booking := range c.bookings {
+		if booking.isExpired() {
+			delete(c.bookings, locator)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"kevinjuniawan/bookcabin/config"
+	"kevinjuniawan/bookcabin/internal"
+)
+
+func TestMemoryGetSortedFlightsByParams(t *testing.T) {
+	flights := []internal.Flight{
+		{ID: "QZ7250_AirAsia", Price: internal.Price{Amount: 500000, Currency: "IDR"}},
+		{ID: "GA400_GarudaIndonesia", Price: internal.Price{Amount: 900000, Currency: "IDR"}},
+		{ID: "JT740_LionAir", Price: internal.Price{Amount: 500000, Currency: "IDR"}},
+		{ID: "ID6514_BatikAir", Price: internal.Price{Amount: 500000, Currency: "IDR"}},
+	}
+	tests := []struct {
+		name     string
+		sortType internal.SortType
+		want     []string
+	}{
+		{
+			name:     "ascending ties by id like ZRANGE",
+			sortType: internal.SortLowestPriceType,
+			want:     []string{"ID6514_BatikAir", "JT740_LionAir", "QZ7250_AirAsia", "GA400_GarudaIndonesia"},
+		},
+		{
+			name:     "descending ties by reversed id like ZREVRANGE",
+			sortType: internal.SortHighestPriceType,
+			want:     []string{"GA400_GarudaIndonesia", "QZ7250_AirAsia", "JT740_LionAir", "ID6514_BatikAir"},
+		},
+	}
+	c := NewMemoryCacheService(&config.Config{CacheTTL: time.Minute})
+	params := internal.GetFlightsParams{Origin: "CGK", Destination: "DPS", DepartureDate: "2025-12-15", CabinClass: "economy"}
+	if err := c.SetFlights(context.Background(), flights, params, 0); err != nil {
+		t.Fatalf("SetFlights() Err : %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params.SortType = tt.sortType
+			sorted, err := c.GetSortedFlightsByParams(context.Background(), params)
+			if err != nil {
+				t.Fatalf("GetSortedFlightsByParams() Err : %v", err)
+			}
+			got := make([]string, len(sorted))
+			for i, flight := range sorted {
+				got[i] = flight.ID
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("GetSortedFlightsByParams() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryTravellerProfileTenant(t *testing.T) {
+	c := NewMemoryCacheService(&config.Config{CacheTTL: time.Minute})
+	profile := internal.TravellerProfile{ID: "traveller-1", TenantID: "acme", HomeAirport: "CGK"}
+	if err := c.SetTravellerProfile(context.Background(), profile); err != nil {
+		t.Fatalf("SetTravellerProfile() Err : %v", err)
+	}
+	tests := []struct {
+		name     string
+		tenantID string
+		wantErr  error
+	}{
+		{name: "owner tenant", tenantID: "acme"},
+		{name: "other tenant", tenantID: "globex", wantErr: internal.ErrTravellerProfileNotFound},
+		{name: "without tenant", tenantID: "", wantErr: internal.ErrTravellerProfileNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.GetTravellerProfile(context.Background(), tt.tenantID, profile.ID)
+			if err != tt.wantErr {
+				t.Fatalf("GetTravellerProfile() Err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.HomeAirport != profile.HomeAirport {
+				t.Errorf("GetTravellerProfile() home airport = %s, want %s", got.HomeAirport, profile.HomeAirport)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"kevinjuniawan/bookcabin/internal"
+	"log"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const priceRulesKey = "price_rules" // JSON array of the active price rules, replace PRICE_RULES_FILE when set
+
+func (c *CacheService) GetPriceRules(ctx context.Context) ([]internal.PriceRule, error) {
+	rulesJSON, err := c.Client.Get(ctx, priceRulesKey).Result()
+	if err == redis.Nil {
+		return nil, internal.ErrPriceRulesNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	rules := []internal.PriceRule{}
+	err = json.Unmarshal([]byte(rulesJSON), &rules)
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (c *CacheService) SetPriceRules(ctx context.Context, rules []internal.PriceRule) error {
+	rulesJSON, err := json.Marshal(rules)
+	if err != nil {
+		log.Printf("fail to marshal price rules, Err : %v\n", err)
+		return err
+	}
+	return c.Client.Set(ctx, priceRulesKey, rulesJSON, 0).Err()
+}
+
+func (c *MemoryCacheService) GetPriceRules(ctx context.Context) ([]internal.PriceRule, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.priceRules == nil {
+		return nil, internal.ErrPriceRulesNotFound
+	}
+	return c.priceRules, nil
+}
+
+func (c *MemoryCacheService) SetPriceRules(ctx context.Context, rules []internal.PriceRule) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.priceRules = rules
+	return nil
+}
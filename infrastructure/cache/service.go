@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"kevinjuniawan/bookcabin/config"
 	"kevinjuniawan/bookcabin/internal"
@@ -13,8 +14,12 @@ import (
 )
 
 type CacheService struct {
-	Client *redis.Client
-	Cfg    *config.Config
+	Client       *redis.Client
+	Scorer       *internal.Scorer
+	Cfg          *config.Config
+	rebuildLocks *rebuildLocks
+	limiter      *localLimiter
+	consistency  *consistencyCounters
 }
 
 type ServiceParams struct {
@@ -33,14 +38,83 @@ func NewCacheService(ctx context.Context, params ServiceParams) *CacheService {
 	if err := client.Ping(ctx).Err(); err != nil {
 		panic(err)
 	}
-	return &CacheService{
-		Client: client,
-		Cfg:    params.Cfg,
+	c := &CacheService{
+		Client:       client,
+		Scorer:       internal.NewScorer(*params.Cfg),
+		Cfg:          params.Cfg,
+		rebuildLocks: newRebuildLocks(),
+		limiter:      newLocalLimiter(),
+		consistency:  &consistencyCounters{},
 	}
+	go func() {
+		if err := c.MigrateSchema(context.Background()); err != nil {
+			log.Printf("fail to migrate cache schema, Err : %v\n", err)
+		}
+	}()
+	return c
 }
 
-func (c *CacheService) GetSortedFlightsByParams(ctx context.Context, params internal.GetFlightsParams) (flightList []internal.Flight, err error) {
-	key, isAscending := makeKey(params)
+// GetSortedFlightsByParams return internal.ErrCacheMiss to the caller that should rebuild the route, only one replica at a time
+// get the rebuild lock, the others are served stale flights or wait shortly for the rebuild result
+func (c *CacheService) GetSortedFlightsByParams(ctx context.Context, params internal.GetFlightsParams) ([]internal.Flight, error) {
+	flights, _, err := c.sortedFlightsByParams(ctx, params)
+	return flights, err
+}
+
+// sortedFlightsByParams is GetSortedFlightsByParams telling whether the flights are fresh or stale
+func (c *CacheService) sortedFlightsByParams(ctx context.Context, params internal.GetFlightsParams) (flights []internal.Flight, isFresh bool, err error) {
+	routeKey := params.RouteKey()
+	flights, err = c.getSortedFlights(ctx, params)
+	if err != nil && err != internal.ErrCacheMiss {
+		return nil, false, err
+	}
+	isCached := err == nil
+	fresh, err := c.Client.Exists(ctx, freshKey(routeKey)).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if isCached && fresh == 1 {
+		return flights, true, nil
+	}
+
+	if c.rebuildLocks.isHeld(routeKey, c.Cfg.RebuildLockTTL) {
+		if isCached {
+			return flights, false, nil
+		}
+		return nil, false, internal.ErrCacheMiss // rebuild is in progress on this instance, the search join it
+	}
+	acquired, err := c.acquireRebuildLock(ctx, routeKey)
+	if err != nil {
+		return nil, false, err
+	}
+	if acquired {
+		return nil, false, internal.ErrCacheMiss
+	}
+	if isCached {
+		return flights, false, nil
+	}
+
+	isRebuilt, err := c.waitForRebuild(ctx, routeKey)
+	if err != nil {
+		return nil, false, err
+	}
+	if !isRebuilt {
+		return nil, false, internal.ErrCacheMiss
+	}
+	flights, err = c.getSortedFlights(ctx, params)
+	return flights, err == nil, err
+}
+
+func (c *CacheService) getSortedFlights(ctx context.Context, params internal.GetFlightsParams) (flightList []internal.Flight, err error) {
+	routeKey := params.RouteKey()
+	snapshot, err := c.Client.Get(ctx, snapshotPointerKey(routeKey)).Result()
+	if err == redis.Nil {
+		return nil, internal.ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	key, isAscending := makeSnapshotKey(params, snapshot)
 	var sortedFlightIDs []redis.Z
 	if isAscending {
 		sortedFlightIDs, err = c.Client.ZRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
@@ -58,9 +132,9 @@ func (c *CacheService) GetSortedFlightsByParams(ctx context.Context, params inte
 		return nil, err
 	}
 	if len(sortedFlightIDs) == 0 {
-		return nil, redis.Nil
+		return nil, internal.ErrCacheMiss
 	}
-	return c.ContructFlightByZSetMember(ctx, sortedFlightIDs)
+	return c.ContructFlightByZSetMember(ctx, params, snapshot, sortedFlightIDs)
 }
 
 func (c *CacheService) GetSortedFlightsByPrice(ctx context.Context, origin, destination, departureDate string, isAscending bool) (flightList []internal.Flight, err error) {
@@ -116,125 +190,243 @@ func (c *CacheService) GetSortedFlightsByBestValue(ctx context.Context, origin,
 	})
 }
 
+// SetFlights write the whole snapshot in one MULTI/EXEC transaction, readers see either the previous or the new snapshot.
+// Flights are fresh for ttl (CACHE_TTL when 0) and stale for CACHE_STALE_TTL after. The fence key and the snapshot pointer
+// are watched, so a rebuild whose lock was taken over by a newer holder, or racing another snapshot write, is aborted and not written
 func (c *CacheService) SetFlights(ctx context.Context, flights []internal.Flight, params internal.GetFlightsParams, ttl time.Duration) error {
-	log.Printf("set %d flights to cache\n", len(flights))
-	for _, flight := range flights {
+	routeKey := params.RouteKey()
+	token, isLocked := c.rebuildLocks.take(routeKey)
+	if isLocked {
+		defer func() {
+			if err := c.releaseRebuildLock(ctx, routeKey, token); err != nil {
+				log.Printf("[%s]fail to release rebuild lock, Err : %v\n", routeKey, err)
+			}
+		}()
+	}
+
+	if ttl == 0 {
+		ttl = c.Cfg.CacheTTL
+	}
+	keyTTL := ttl + c.Cfg.CacheStaleTTL
+	snapshot := newSnapshotID()
+	flightJSONs := make([][]byte, len(flights))
+	for i, flight := range flights {
 		flightJSON, err := json.Marshal(flight)
 		if err != nil {
 			log.Printf("[%s]fail to marshal flight, Err : %v\n", flight.ID, err)
 			return err
 		}
+		flightJSONs[i] = flightJSON
+	}
+	members := c.zsetMembers(flights)
 
-		err = c.Client.Set(ctx, flight.ID, flightJSON, ttl).Err()
-		if err != nil {
-			log.Printf("[%s]fail to set flight, Err : %v\n", flight.ID, err)
-			return err
-		}
-
-		err = c.addMemberToZSetPrice(ctx, flight, params)
-		if err != nil {
-			log.Printf("[%s]fail to add member to zset price, Err : %v\n", flight.ID, err)
-			return err
-		}
-
-		err = c.addMemberToZSetDepartureTime(ctx, flight, params)
-		if err != nil {
-			log.Printf("[%s]fail to add member to zset departure time, Err : %v\n", flight.ID, err)
-			return err
-		}
-
-		err = c.addMemberToZSetArrivalTime(ctx, flight, params)
-		if err != nil {
-			log.Printf("[%s]fail to add member to zset arrival time, Err : %v\n", flight.ID, err)
-			return err
+	err := c.Client.Watch(ctx, func(tx *redis.Tx) error {
+		if isLocked {
+			isFenced, err := c.isFenced(ctx, tx, routeKey, token)
+			if err != nil {
+				return err
+			}
+			if isFenced {
+				return errRebuildFenced
+			}
 		}
-
-		err = c.addMemberToZSetDuration(ctx, flight, params)
+		previousKeys, err := c.snapshotKeys(ctx, tx, params)
 		if err != nil {
-			log.Printf("[%s]fail to add member to zset duration, Err : %v\n", flight.ID, err)
 			return err
 		}
 
-		err = c.addMemberToZSetBestValue(ctx, flight, params)
-		if err != nil {
-			log.Printf("[%s]fail to add member to zset best value, Err : %v\n", flight.ID, err)
-			return err
-		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, flight := range flights {
+				blobKey := flightBlobKey(routeKey, snapshot, flight.ID)
+				pipe.Set(ctx, blobKey, flightJSONs[i], keyTTL)
+				pipe.Set(ctx, latestFlightKey(flight.ID), blobKey, keyTTL)
+			}
+			for sortType, sortMembers := range members {
+				params.SortType = sortType
+				key, _ := makeSnapshotKey(params, snapshot)
+				pipe.ZAdd(ctx, key, sortMembers...)
+				pipe.Expire(ctx, key, keyTTL)
+			}
+			pipe.Set(ctx, snapshotPointerKey(routeKey), snapshot, keyTTL)
+			pipe.Set(ctx, freshKey(routeKey), time.Now().Unix(), ttl)
+			// readers still on the previous snapshot get a short grace before it is gone
+			for _, key := range previousKeys {
+				pipe.Expire(ctx, key, previousSnapshotTTL)
+			}
+			return nil
+		})
+		return err
+	}, fenceKey(routeKey), snapshotPointerKey(routeKey))
+	if errors.Is(err, errRebuildFenced) || errors.Is(err, redis.TxFailedErr) {
+		log.Printf("[%s]skip set flights, rebuild lock is taken by newer holder or another snapshot is written\n", routeKey)
+		return nil
+	}
+	if err != nil {
+		log.Printf("[%s]fail to set flights snapshot, Err : %v\n", routeKey, err)
+		return err
 	}
+	log.Printf("[%s]set %d flights to cache snapshot %s\n", routeKey, len(flights), snapshot)
 	return nil
 }
 
-func (c *CacheService) addMemberToZSetPrice(ctx context.Context, flight internal.Flight, params internal.GetFlightsParams) error {
-	params.SortType = internal.SortLowestPriceType
-	key, _ := makeKey(params)
-	return c.Client.ZAdd(ctx, key, &redis.Z{
-		Score:  float64(flight.Price.AmountInIDR()),
-		Member: flight.ID,
-	}).Err()
-}
-
-func (c *CacheService) addMemberToZSetDepartureTime(ctx context.Context, flight internal.Flight, params internal.GetFlightsParams) error {
-	params.SortType = internal.SortDepartureType
-	key, _ := makeKey(params)
-	return c.Client.ZAdd(ctx, key, &redis.Z{
-		Score:  float64(flight.Departure.Timestamp),
-		Member: flight.ID,
-	}).Err()
-}
-
-func (c *CacheService) addMemberToZSetArrivalTime(ctx context.Context, flight internal.Flight, params internal.GetFlightsParams) error {
-	params.SortType = internal.SortArrivalType
-	key, _ := makeKey(params)
-	return c.Client.ZAdd(ctx, key, &redis.Z{
-		Score:  float64(flight.Arrival.Timestamp),
-		Member: flight.ID,
-	}).Err()
+// zsetMembers score every flight for each cached sort
+func (c *CacheService) zsetMembers(flights []internal.Flight) map[internal.SortType][]*redis.Z {
+	members := map[internal.SortType][]*redis.Z{}
+	for _, flight := range flights {
+		members[internal.SortLowestPriceType] = append(members[internal.SortLowestPriceType], &redis.Z{Score: float64(flight.Price.AmountInIDR()), Member: flight.ID})
+		members[internal.SortDepartureType] = append(members[internal.SortDepartureType], &redis.Z{Score: float64(flight.Departure.Timestamp), Member: flight.ID})
+		members[internal.SortArrivalType] = append(members[internal.SortArrivalType], &redis.Z{Score: float64(flight.Arrival.Timestamp), Member: flight.ID})
+		members[internal.SortShortestDurationType] = append(members[internal.SortShortestDurationType], &redis.Z{Score: float64(flight.Duration.TotalMinute), Member: flight.ID})
+		members[internal.SortBestValueType] = append(members[internal.SortBestValueType], &redis.Z{Score: c.Scorer.ScoreWithDefaultProfile(flight).Total, Member: flight.ID})
+	}
+	return members
 }
 
-func (c *CacheService) addMemberToZSetDuration(ctx context.Context, flight internal.Flight, params internal.GetFlightsParams) error {
-	params.SortType = internal.SortShortestDurationType
-	key, _ := makeKey(params)
-	return c.Client.ZAdd(ctx, key, &redis.Z{
-		Score:  float64(flight.Duration.TotalMinute),
-		Member: flight.ID,
-	}).Err()
+// snapshotKeys list the sorted sets and flight blobs of the snapshot currently served for the route
+func (c *CacheService) snapshotKeys(ctx context.Context, tx *redis.Tx, params internal.GetFlightsParams) ([]string, error) {
+	routeKey := params.RouteKey()
+	snapshot, err := tx.Get(ctx, snapshotPointerKey(routeKey)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	params.SortType = internal.SortLowestPriceType
+	key, _ := makeSnapshotKey(params, snapshot)
+	flightIDs, err := tx.ZRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	keys := zsetKeys(params, snapshot)
+	for _, flightID := range flightIDs {
+		keys = append(keys, flightBlobKey(routeKey, snapshot, flightID))
+	}
+	return keys, nil
 }
 
-func (c *CacheService) addMemberToZSetBestValue(ctx context.Context, flight internal.Flight, params internal.GetFlightsParams) error {
-	params.SortType = internal.SortBestValueType
-	key, _ := makeKey(params)
-	return c.Client.ZAdd(ctx, key, &redis.Z{
-		Score:  float64(internal.CalculateBestValue(flight)),
-		Member: flight.ID,
-	}).Err()
+func zsetKeys(params internal.GetFlightsParams, snapshot string) []string {
+	keys := []string{}
+	for _, sortType := range []internal.SortType{internal.SortLowestPriceType, internal.SortShortestDurationType, internal.SortDepartureType, internal.SortArrivalType, internal.SortBestValueType} {
+		params.SortType = sortType
+		key, _ := makeSnapshotKey(params, snapshot)
+		keys = append(keys, key)
+	}
+	return keys
 }
 
-func (c *CacheService) ContructFlightByZSetMember(ctx context.Context, setMember []redis.Z) (flightList []internal.Flight, err error) {
+// ContructFlightByZSetMember skip members whose flight blob expired or was evicted, the index is healed in the background
+// and too many missing flights is a cache miss
+func (c *CacheService) ContructFlightByZSetMember(ctx context.Context, params internal.GetFlightsParams, snapshot string, setMember []redis.Z) (flightList []internal.Flight, err error) {
+	routeKey := params.RouteKey()
 	flightIDs := make([]string, len(setMember))
+	blobKeys := make([]string, len(setMember))
 	for i, member := range setMember {
-		flightIDs[i] = member.Member.(string)
+		flightIDs[i], _ = member.Member.(string)
+		blobKeys[i] = flightBlobKey(routeKey, snapshot, flightIDs[i])
 	}
-	return c.ConstructFlightByFlightID(ctx, flightIDs)
+	flightList, missing, err := c.constructFlights(ctx, blobKeys)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) == 0 {
+		return flightList, nil
+	}
+
+	missingIDs := make([]string, len(missing))
+	for i, index := range missing {
+		missingIDs[i] = flightIDs[index]
+	}
+	if c.healDanglingMembers(params, snapshot, missingIDs, len(setMember)) {
+		return nil, internal.ErrCacheMiss
+	}
+	return flightList, nil
 }
 
+// ConstructFlightByFlightID read the flights of the latest snapshot containing them
 func (c *CacheService) ConstructFlightByFlightID(ctx context.Context, flightIDs []string) (flightList []internal.Flight, err error) {
-	flights, err := c.Client.MGet(ctx, flightIDs...).Result()
+	latestKeys := make([]string, len(flightIDs))
+	for i, flightID := range flightIDs {
+		latestKeys[i] = latestFlightKey(flightID)
+	}
+	pointers, err := c.Client.MGet(ctx, latestKeys...).Result()
 	if err != nil {
 		return nil, err
 	}
-	for _, flight := range flights {
+	blobKeys := []string{}
+	for _, pointer := range pointers {
+		if blobKey, isString := pointer.(string); isString {
+			blobKeys = append(blobKeys, blobKey)
+		}
+	}
+	if len(blobKeys) == 0 {
+		return nil, nil
+	}
+	flightList, _, err = c.constructFlights(ctx, blobKeys)
+	return flightList, err
+}
+
+// constructFlights return the index of blob keys missing or unreadable next to the flights found
+func (c *CacheService) constructFlights(ctx context.Context, blobKeys []string) (flightList []internal.Flight, missing []int, err error) {
+	flights, err := c.Client.MGet(ctx, blobKeys...).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, flight := range flights {
+		flightData, isString := flight.(string)
+		if !isString {
+			missing = append(missing, i) // flight expired or evicted
+			continue
+		}
 		var flightObj internal.Flight
-		err = json.Unmarshal([]byte(flight.(string)), &flightObj)
+		err = json.Unmarshal([]byte(flightData), &flightObj)
 		if err != nil {
-			return nil, err
+			log.Printf("[%s]fail to unmarshal flight, Err : %v\n", blobKeys[i], err)
+			missing = append(missing, i)
+			continue
 		}
 		flightList = append(flightList, flightObj)
 	}
-	return flightList, nil
+	return flightList, missing, nil
+}
+
+func (c *CacheService) GetFlightByID(ctx context.Context, flightID string) (internal.Flight, error) {
+	flights, err := c.ConstructFlightByFlightID(ctx, []string{flightID})
+	if err != nil {
+		return internal.Flight{}, err
+	}
+	if len(flights) == 0 {
+		return internal.Flight{}, internal.ErrFlightNotFound
+	}
+	return flights[0], nil
+}
+
+// UpdateFlight replace the cached flight and keep its remaining TTL
+func (c *CacheService) UpdateFlight(ctx context.Context, flight internal.Flight) error {
+	flightJSON, err := json.Marshal(flight)
+	if err != nil {
+		log.Printf("[%s]fail to marshal flight, Err : %v\n", flight.ID, err)
+		return err
+	}
+	blobKey, err := c.Client.Get(ctx, latestFlightKey(flight.ID)).Result()
+	if err == redis.Nil {
+		// flight is not part of any snapshot, keep it for the detail lookup only
+		keyTTL := c.Cfg.CacheTTL + c.Cfg.CacheStaleTTL
+		blobKey = detailFlightKey(flight.ID)
+		if err := c.Client.Set(ctx, blobKey, flightJSON, keyTTL).Err(); err != nil {
+			return err
+		}
+		return c.Client.Set(ctx, latestFlightKey(flight.ID), blobKey, keyTTL).Err()
+	}
+	if err != nil {
+		return err
+	}
+	return c.Client.Set(ctx, blobKey, flightJSON, redis.KeepTTL).Err()
 }
 
+// makeKey is the logical key of a sorted route, makeSnapshotKey is the sorted set of one snapshot of it
 func makeKey(params internal.GetFlightsParams) (string, bool) {
-	baseKey := fmt.Sprintf("flights:%s:%s:%s", params.Origin, params.Destination, params.DepartureDate)
+	baseKey := routePrefix(params.RouteKey())
 	switch params.SortType {
 	case internal.SortLowestPriceType:
 		return baseKey + ":" + MapSortTypeToKey[params.SortType], true
@@ -254,14 +446,111 @@ func makeKey(params internal.GetFlightsParams) (string, bool) {
 	return "", false
 }
 
-func (c *CacheService) IsRequestLimiterExceeded(ctx context.Context, URI string) bool {
-	key := fmt.Sprintf("request_limiter:%s", URI)
-	counter := c.Client.Incr(ctx, key).Val()
-	if counter == 1 {
-		c.Client.Expire(ctx, key, c.Cfg.RequestLimiterTTL)
+func makeSnapshotKey(params internal.GetFlightsParams, snapshot string) (string, bool) {
+	key, isAscending := makeKey(params)
+	return key + ":" + snapshot, isAscending
+}
+
+func (c *CacheService) CreateBooking(ctx context.Context, booking internal.Booking, ttl time.Duration) error {
+	bookingJSON, err := json.Marshal(booking)
+	if err != nil {
+		log.Printf("[%s]fail to marshal booking, Err : %v\n", booking.Locator, err)
+		return err
 	}
-	if counter > c.Cfg.RequestLimiterMax+1 {
-		return true
+	created, err := c.Client.SetNX(ctx, fmt.Sprintf("booking:%s", booking.Locator), bookingJSON, ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !created {
+		return internal.ErrBookingExists
 	}
-	return false
+	return nil
+}
+
+func (c *CacheService) UpdateBooking(ctx context.Context, booking internal.Booking) error {
+	bookingJSON, err := json.Marshal(booking)
+	if err != nil {
+		log.Printf("[%s]fail to marshal booking, Err : %v\n", booking.Locator, err)
+		return err
+	}
+	updated, err := c.Client.SetXX(ctx, fmt.Sprintf("booking:%s", booking.Locator), bookingJSON, redis.KeepTTL).Result()
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return internal.ErrBookingNotFound
+	}
+	return nil
+}
+
+func (c *CacheService) GetBooking(ctx context.Context, locator string) (internal.Booking, error) {
+	bookingJSON, err := c.Client.Get(ctx, fmt.Sprintf("booking:%s", locator)).Result()
+	if err == redis.Nil {
+		return internal.Booking{}, internal.ErrBookingNotFound
+	}
+	if err != nil {
+		return internal.Booking{}, err
+	}
+	var booking internal.Booking
+	err = json.Unmarshal([]byte(bookingJSON), &booking)
+	if err != nil {
+		return internal.Booking{}, err
+	}
+	return booking, nil
+}
+
+// travellerProfileKey namespace profiles per tenant, profiles saved while authentication is disabled keep the untenanted key
+func travellerProfileKey(tenantID, profileID string) string {
+	if tenantID == "" {
+		return fmt.Sprintf("traveller_profile:%s", profileID)
+	}
+	return fmt.Sprintf("tenant:%s:traveller_profile:%s", tenantID, profileID)
+}
+
+func (c *CacheService) GetTravellerProfile(ctx context.Context, tenantID, profileID string) (internal.TravellerProfile, error) {
+	profileJSON, err := c.Client.Get(ctx, travellerProfileKey(tenantID, profileID)).Result()
+	if err == redis.Nil {
+		return internal.TravellerProfile{}, internal.ErrTravellerProfileNotFound
+	}
+	if err != nil {
+		return internal.TravellerProfile{}, err
+	}
+	var profile internal.TravellerProfile
+	err = json.Unmarshal([]byte(profileJSON), &profile)
+	if err != nil {
+		return internal.TravellerProfile{}, err
+	}
+	return profile, nil
+}
+
+func (c *CacheService) SetTravellerProfile(ctx context.Context, profile internal.TravellerProfile) error {
+	profileJSON, err := json.Marshal(profile)
+	if err != nil {
+		log.Printf("[%s]fail to marshal traveller profile, Err : %v\n", profile.ID, err)
+		return err
+	}
+	return c.Client.Set(ctx, travellerProfileKey(profile.TenantID, profile.ID), profileJSON, 0).Err()
+}
+
+// AllowRequest fallback to the per instance limiter while Redis is unavailable
+func (c *CacheService) AllowRequest(ctx context.Context, clientID string, tier internal.RateLimitTier) internal.RateLimitResult {
+	if tier.Limit <= 0 {
+		return internal.RateLimitResult{Allowed: true}
+	}
+	key := rateLimitKey(clientID, tier)
+	result, err := slidingWindowScript.Run(ctx, c.Client, []string{key}, tier.Window.Milliseconds(), tier.Limit, rateLimitMember()).Int64Slice()
+	if err != nil || len(result) != 3 {
+		log.Printf("[%s]fail to run rate limiter, use local limiter, Err : %v\n", key, err)
+		return c.limiter.allow(clientID, tier)
+	}
+	return internal.RateLimitResult{
+		Allowed:    result[0] == 1,
+		Limit:      tier.Limit,
+		Remaining:  result[1],
+		RetryAfter: time.Duration(result[2]) * time.Millisecond,
+	}
+}
+
+func (c *CacheService) Ping(ctx context.Context) error {
+	return c.Client.Ping(ctx).Err()
 }
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"kevinjuniawan/bookcabin/internal"
+	"log"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func tenantKey(tenantID string) string {
+	return fmt.Sprintf("tenant:%s", tenantID)
+}
+
+// apiKeyKey is addressed by the key hash so the key itself is never stored
+func apiKeyKey(hash string) string {
+	return fmt.Sprintf("api_key:%s", hash)
+}
+
+// apiKeyIDKey point the key ID to its hash for revocation
+func apiKeyIDKey(keyID string) string {
+	return fmt.Sprintf("api_key_id:%s", keyID)
+}
+
+func (c *CacheService) GetTenant(ctx context.Context, tenantID string) (internal.Tenant, error) {
+	tenantJSON, err := c.Client.Get(ctx, tenantKey(tenantID)).Result()
+	if err == redis.Nil {
+		return internal.Tenant{}, internal.ErrTenantNotFound
+	}
+	if err != nil {
+		return internal.Tenant{}, err
+	}
+	var tenant internal.Tenant
+	err = json.Unmarshal([]byte(tenantJSON), &tenant)
+	if err != nil {
+		return internal.Tenant{}, err
+	}
+	return tenant, nil
+}
+
+func (c *CacheService) SetTenant(ctx context.Context, tenant internal.Tenant) error {
+	tenantJSON, err := json.Marshal(tenant)
+	if err != nil {
+		log.Printf("[%s]fail to marshal tenant, Err : %v\n", tenant.ID, err)
+		return err
+	}
+	return c.Client.Set(ctx, tenantKey(tenant.ID), tenantJSON, 0).Err()
+}
+
+func (c *CacheService) GetAPIKey(ctx context.Context, hash string) (internal.APIKey, error) {
+	keyJSON, err := c.Client.Get(ctx, apiKeyKey(hash)).Result()
+	if err == redis.Nil {
+		return internal.APIKey{}, internal.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return internal.APIKey{}, err
+	}
+	var key internal.APIKey
+	err = json.Unmarshal([]byte(keyJSON), &key)
+	if err != nil {
+		return internal.APIKey{}, err
+	}
+	key.Hash = hash
+	return key, nil
+}
+
+func (c *CacheService) SaveAPIKey(ctx context.Context, key internal.APIKey) error {
+	keyJSON, err := json.Marshal(key)
+	if err != nil {
+		log.Printf("[%s]fail to marshal api key, Err : %v\n", key.ID, err)
+		return err
+	}
+	_, err = c.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, apiKeyKey(key.Hash), keyJSON, 0)
+		pipe.Set(ctx, apiKeyIDKey(key.ID), key.Hash, 0)
+		return nil
+	})
+	return err
+}
+
+func (c *CacheService) RevokeAPIKey(ctx context.Context, keyID string) error {
+	hash, err := c.Client.Get(ctx, apiKeyIDKey(keyID)).Result()
+	if err == redis.Nil {
+		return internal.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return err
+	}
+	_, err = c.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, apiKeyKey(hash), apiKeyIDKey(keyID))
+		return nil
+	})
+	return err
+}
+
+func (c *MemoryCacheService) GetTenant(ctx context.Context, tenantID string) (internal.Tenant, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tenant, exist := c.tenants[tenantID]
+	if !exist {
+		return internal.Tenant{}, internal.ErrTenantNotFound
+	}
+	return tenant, nil
+}
+
+func (c *MemoryCacheService) SetTenant(ctx context.Context, tenant internal.Tenant) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tenants[tenant.ID] = tenant
+	return nil
+}
+
+func (c *MemoryCacheService) GetAPIKey(ctx context.Context, hash string) (internal.APIKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, exist := c.apiKeys[hash]
+	if !exist {
+		return internal.APIKey{}, internal.ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+func (c *MemoryCacheService) SaveAPIKey(ctx context.Context, key internal.APIKey) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.apiKeys[key.Hash] = key
+	c.apiKeyIDs[key.ID] = key.Hash
+	return nil
+}
+
+func (c *MemoryCacheService) RevokeAPIKey(ctx context.Context, keyID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	hash, exist := c.apiKeyIDs[keyID]
+	if !exist {
+		return internal.ErrAPIKeyNotFound
+	}
+	delete(c.apiKeys, hash)
+	delete(c.apiKeyIDs, keyID)
+	return nil
+}
@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"context"
+	"kevinjuniawan/bookcabin/internal"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	invalidationChannel      = "cache:invalidation"
+	invalidationRoutePrefix  = "route:"
+	invalidationFlightPrefix = "flight:"
+	flightDetailKeyPrefix    = "flight_detail:"
+	resubscribeMinBackoff    = 100 * time.Millisecond
+	resubscribeMaxBackoff    = 10 * time.Second
+)
+
+// TwoTierCacheService serve hot routes from an in-process LRU in front of Redis, writes are broadcast over Redis
+// pub/sub so every replica drop the same entries
+type TwoTierCacheService struct {
+	*CacheService
+	local *flightLRU
+}
+
+func NewTwoTierCacheService(ctx context.Context, redisCache *CacheService) *TwoTierCacheService {
+	c := &TwoTierCacheService{
+		CacheService: redisCache,
+		local:        newFlightLRU(redisCache.Cfg.LocalCacheMaxEntries, redisCache.Cfg.LocalCacheMaxFlights),
+	}
+	go c.subscribeInvalidation(ctx)
+	return c
+}
+
+func (c *TwoTierCacheService) GetSortedFlightsByParams(ctx context.Context, params internal.GetFlightsParams) ([]internal.Flight, error) {
+	key, _ := makeKey(params)
+	if flights, exist := c.local.get(key); exist {
+		return flights, nil
+	}
+	flights, isFresh, err := c.CacheService.sortedFlightsByParams(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	// stale flights are only served until the rebuild, keeping them locally would outlive it
+	if !isFresh {
+		return flights, nil
+	}
+	c.local.set(key, flights, c.Cfg.LocalCacheTTL)
+	return cloneFlights(flights), nil
+}
+
+func (c *TwoTierCacheService) GetSortedFlightsByPrice(ctx context.Context, origin, destination, departureDate string, isAscending bool) ([]internal.Flight, error) {
+	sortType := internal.SortLowestPriceType
+	if !isAscending {
+		sortType = internal.SortHighestPriceType
+	}
+	return c.GetSortedFlightsByParams(ctx, internal.GetFlightsParams{Origin: origin, Destination: destination, DepartureDate: departureDate, SortType: sortType})
+}
+
+func (c *TwoTierCacheService) GetSortedFlightsByDuration(ctx context.Context, origin, destination, departureDate string, isAscending bool) ([]internal.Flight, error) {
+	sortType := internal.SortShortestDurationType
+	if !isAscending {
+		sortType = internal.SortLongestDurationType
+	}
+	return c.GetSortedFlightsByParams(ctx, internal.GetFlightsParams{Origin: origin, Destination: destination, DepartureDate: departureDate, SortType: sortType})
+}
+
+func (c *TwoTierCacheService) GetSortedFlightsByDepartureTime(ctx context.Context, origin, destination, departureDate string, isAscending bool) ([]internal.Flight, error) {
+	return c.GetSortedFlightsByParams(ctx, internal.GetFlightsParams{Origin: origin, Destination: destination, DepartureDate: departureDate, SortType: internal.SortDepartureType})
+}
+
+func (c *TwoTierCacheService) GetSortedFlightsByArrivalTime(ctx context.Context, origin, destination, departureDate string, isAscending bool) ([]internal.Flight, error) {
+	return c.GetSortedFlightsByParams(ctx, internal.GetFlightsParams{Origin: origin, Destination: destination, DepartureDate: departureDate, SortType: internal.SortArrivalType})
+}
+
+func (c *TwoTierCacheService) GetSortedFlightsByBestValue(ctx context.Context, origin, destination, departureDate string, isAscending bool) ([]internal.Flight, error) {
+	return c.GetSortedFlightsByParams(ctx, internal.GetFlightsParams{Origin: origin, Destination: destination, DepartureDate: departureDate, SortType: internal.SortBestValueType})
+}
+
+func (c *TwoTierCacheService) SetFlights(ctx context.Context, flights []internal.Flight, params internal.GetFlightsParams, ttl time.Duration) error {
+	err := c.CacheService.SetFlights(ctx, flights, params, ttl)
+	c.invalidate(ctx, invalidationRoutePrefix+params.RouteKey())
+	return err
+}
+
+func (c *TwoTierCacheService) GetFlightByID(ctx context.Context, flightID string) (internal.Flight, error) {
+	if flights, exist := c.local.get(flightDetailKeyPrefix + flightID); exist {
+		return flights[0], nil
+	}
+	flight, err := c.CacheService.GetFlightByID(ctx, flightID)
+	if err != nil {
+		return internal.Flight{}, err
+	}
+	c.local.set(flightDetailKeyPrefix+flightID, []internal.Flight{flight}, c.Cfg.LocalCacheTTL)
+	return flight, nil
+}
+
+func (c *TwoTierCacheService) UpdateFlight(ctx context.Context, flight internal.Flight) error {
+	err := c.CacheService.UpdateFlight(ctx, flight)
+	c.invalidate(ctx, invalidationFlightPrefix+flight.ID)
+	return err
+}
+
+// invalidate drop the local entries right away, then tell the other replicas
+func (c *TwoTierCacheService) invalidate(ctx context.Context, messages ...string) {
+	for _, message := range messages {
+		c.applyInvalidation(message)
+	}
+	_, err := c.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, message := range messages {
+			pipe.Publish(ctx, invalidationChannel, message)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[%s]fail to publish cache invalidation, Err : %v\n", messages[0], err)
+	}
+}
+
+func (c *TwoTierCacheService) applyInvalidation(message string) {
+	switch {
+	case strings.HasPrefix(message, invalidationRoutePrefix):
+		routeKey := strings.TrimPrefix(message, invalidationRoutePrefix)
+		c.local.removePrefix(routePrefix(routeKey) + ":")
+		// flight IDs carry the route scope, so the flight details of the route are dropped with it
+		c.local.removeContaining(flightDetailKeyPrefix, "."+internal.RouteScope(routeKey)+"_")
+	case strings.HasPrefix(message, invalidationFlightPrefix):
+		c.local.removeFlight(strings.TrimPrefix(message, invalidationFlightPrefix))
+	}
+}
+
+// subscribeInvalidation resubscribe with backoff until ctx is done, invalidations published while unsubscribed are lost
+// so the local entries are dropped when the subscription fail and again once it is back
+func (c *TwoTierCacheService) subscribeInvalidation(ctx context.Context) {
+	backoff := resubscribeMinBackoff
+	for {
+		subscribed, err := c.receiveInvalidation(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		c.local.clear()
+		if subscribed {
+			backoff = resubscribeMinBackoff
+		}
+		log.Printf("fail to receive cache invalidation, resubscribe in %s, Err : %v\n", backoff, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, resubscribeMaxBackoff)
+	}
+}
+
+// receiveInvalidation apply invalidations until the subscription fail, subscribed is true when it was confirmed
+func (c *TwoTierCacheService) receiveInvalidation(ctx context.Context) (subscribed bool, err error) {
+	pubsub := c.Client.Subscribe(ctx, invalidationChannel)
+	defer pubsub.Close()
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return false, err
+	}
+	// entries cached before the subscription may have missed invalidations
+	c.local.clear()
+	for {
+		message, err := pubsub.ReceiveMessage(ctx)
+		if err != nil {
+			return true, err
+		}
+		c.applyInvalidation(message.Payload)
+	}
+}
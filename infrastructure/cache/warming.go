@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"kevinjuniawan/bookcabin/internal"
+	"log"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	warmingRoutesKey   = "warming:routes"   // list of ORIGIN-DESTINATION:DAYS managed by operators
+	warmingLastRunKey  = "warming:last_run" // stats of the latest run
+	warmingRunsChannel = "warming:runs"     // every run stats are published
+)
+
+func (c *CacheService) IsFresh(ctx context.Context, params internal.GetFlightsParams) (bool, error) {
+	fresh, err := c.Client.Exists(ctx, freshKey(params.RouteKey())).Result()
+	if err != nil {
+		return false, err
+	}
+	return fresh == 1, nil
+}
+
+func (c *CacheService) GetWarmingRoutes(ctx context.Context) ([]string, error) {
+	return c.Client.LRange(ctx, warmingRoutesKey, 0, -1).Result()
+}
+
+func (c *CacheService) SaveWarmingRun(ctx context.Context, run internal.WarmingRun) error {
+	runJSON, err := json.Marshal(run)
+	if err != nil {
+		log.Printf("fail to marshal warming run, Err : %v\n", err)
+		return err
+	}
+	_, err = c.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, warmingLastRunKey, runJSON, 0)
+		pipe.Publish(ctx, warmingRunsChannel, runJSON)
+		return nil
+	})
+	return err
+}
+
+func (c *CacheService) GetWarmingRun(ctx context.Context) (internal.WarmingRun, error) {
+	runJSON, err := c.Client.Get(ctx, warmingLastRunKey).Result()
+	if err == redis.Nil {
+		return internal.WarmingRun{}, internal.ErrWarmingRunNotFound
+	}
+	if err != nil {
+		return internal.WarmingRun{}, err
+	}
+	var run internal.WarmingRun
+	err = json.Unmarshal([]byte(runJSON), &run)
+	if err != nil {
+		return internal.WarmingRun{}, err
+	}
+	return run, nil
+}
@@ -0,0 +1,8 @@
+package internal
+
+import "context"
+
+type IAnalytics interface {
+	RecordSearch(ctx context.Context, event SearchEvent) error
+	GetAnalytics(ctx context.Context, params GetAnalyticsParams) (AnalyticsReport, error)
+}
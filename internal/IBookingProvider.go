@@ -0,0 +1,19 @@
+package internal
+
+import "time"
+
+type HoldSeatsParams struct {
+	Provider      string
+	Origin        string
+	Destination   string
+	DepartureDate string
+	FlightNumber  string
+	BookingClass  string
+	Seats         int16
+	TTL           time.Duration
+}
+
+type IBookingProvider interface {
+	HoldSeats(params HoldSeatsParams) (string, error)
+	ReleaseSeats(provider string, holdReference string) error
+}
@@ -2,9 +2,17 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+var ErrCacheMiss = errors.New("cache is missed")
+
+type CacheConsistencyStats struct {
+	DanglingMembers  int64 `json:"dangling_members"`  // indexed flights found without their flight data
+	RefetchTriggered int64 `json:"refetch_triggered"` // routes refetched because too many flights were missing
+}
+
 type ICache interface {
 	GetSortedFlightsByParams(ctx context.Context, params GetFlightsParams) ([]Flight, error)
 	GetSortedFlightsByPrice(ctx context.Context, origin, destination, departureDate string, isAscending bool) ([]Flight, error)
@@ -13,4 +21,26 @@ type ICache interface {
 	GetSortedFlightsByArrivalTime(ctx context.Context, origin, destination, departureDate string, isAscending bool) ([]Flight, error)
 	GetSortedFlightsByBestValue(ctx context.Context, origin, destination, departureDate string, isAscending bool) ([]Flight, error)
 	SetFlights(ctx context.Context, flights []Flight, params GetFlightsParams, ttl time.Duration) error
+	AcquireRebuildLock(ctx context.Context, params GetFlightsParams) (bool, error)
+	ReleaseRebuildLock(ctx context.Context, params GetFlightsParams) error
+	GetFlightByID(ctx context.Context, flightID string) (Flight, error)
+	UpdateFlight(ctx context.Context, flight Flight) error
+	CreateBooking(ctx context.Context, booking Booking, ttl time.Duration) error
+	UpdateBooking(ctx context.Context, booking Booking) error
+	GetBooking(ctx context.Context, locator string) (Booking, error)
+	GetTravellerProfile(ctx context.Context, tenantID, profileID string) (TravellerProfile, error)
+	SetTravellerProfile(ctx context.Context, profile TravellerProfile) error
+	GetTenant(ctx context.Context, tenantID string) (Tenant, error)
+	SetTenant(ctx context.Context, tenant Tenant) error
+	GetAPIKey(ctx context.Context, hash string) (APIKey, error)
+	SaveAPIKey(ctx context.Context, key APIKey) error
+	RevokeAPIKey(ctx context.Context, keyID string) error
+	GetPriceRules(ctx context.Context) ([]PriceRule, error)
+	SetPriceRules(ctx context.Context, rules []PriceRule) error
+	Ping(ctx context.Context) error
+	ConsistencyStats() CacheConsistencyStats
+	IsFresh(ctx context.Context, params GetFlightsParams) (bool, error)
+	GetWarmingRoutes(ctx context.Context) ([]string, error)
+	SaveWarmingRun(ctx context.Context, run WarmingRun) error
+	GetWarmingRun(ctx context.Context) (WarmingRun, error)
 }
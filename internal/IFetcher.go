@@ -8,4 +8,5 @@ type FlightDataResponse struct {
 
 type IFetcher interface {
 	GetFlights(params GetFlightsParams) (FlightDataResponse, error)
+	GetFlight(provider string, params GetFlightsParams, flightID string) (Flight, error)
 }
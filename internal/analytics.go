@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+var ErrInvalidAnalyticsWindow = errors.New("analytics window is invalid")
+
+const (
+	DefaultAnalyticsLimit = 10
+	MaxAnalyticsLimit     = 100
+)
+
+// AnalyticsWindows are counted from hourly buckets, the longest window set how long buckets are kept
+var AnalyticsWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+}
+
+type SearchEvent struct {
+	Origin        string
+	Destination   string
+	DepartureDate string
+	ClientID      string
+	IsCache       bool
+	ResultCount   int
+	SearchedAt    time.Time
+}
+
+func (e SearchEvent) Route() string {
+	return e.Origin + "-" + e.Destination
+}
+
+type GetAnalyticsParams struct {
+	Window string
+	Limit  int
+}
+
+func (p *GetAnalyticsParams) Validate() error {
+	if p.Window == "" {
+		p.Window = "24h"
+	}
+	if _, exist := AnalyticsWindows[p.Window]; !exist {
+		return ErrInvalidAnalyticsWindow
+	}
+	if p.Limit <= 0 {
+		p.Limit = DefaultAnalyticsLimit
+	}
+	p.Limit = min(p.Limit, MaxAnalyticsLimit)
+	return nil
+}
+
+type RouteStat struct {
+	Route         string `json:"route"`
+	DepartureDate string `json:"departure_date,omitempty"`
+	Count         int64  `json:"count"`
+}
+
+type AnalyticsReport struct {
+	Window              string      `json:"window"`
+	From                time.Time   `json:"from"`
+	To                  time.Time   `json:"to"`
+	Searches            int64       `json:"searches"`
+	CacheHits           int64       `json:"cache_hits"`
+	CacheMisses         int64       `json:"cache_misses"`
+	ZeroResults         int64       `json:"zero_results"`
+	UniqueClients       int64       `json:"unique_clients"` // approximated with HyperLogLog
+	TopRoutes           []RouteStat `json:"top_routes"`
+	TopDepartureDates   []RouteStat `json:"top_departure_dates"`
+	TopZeroResultRoutes []RouteStat `json:"top_zero_result_routes"`
+}
+
+// AnalyticsBuckets return the hourly buckets covering the window ending at to, including the bucket of the window start
+func AnalyticsBuckets(to time.Time, window time.Duration) []time.Time {
+	buckets := []time.Time{}
+	from := to.Add(-window).UTC().Truncate(time.Hour)
+	for bucket := to.UTC().Truncate(time.Hour); !bucket.Before(from); bucket = bucket.Add(-time.Hour) {
+		buckets = append(buckets, bucket)
+	}
+	return buckets
+}
+
+// RecordSearch never fail the search, analytics are written in the background
+func (s *InternalService) RecordSearch(event SearchEvent) {
+	if s.Analytics == nil {
+		return
+	}
+	go func() {
+		if err := s.Analytics.RecordSearch(context.Background(), event); err != nil {
+			log.Printf("[%s]fail to record search analytics, Err : %v\n", event.Route(), err)
+		}
+	}()
+}
+
+func (s *InternalService) GetAnalytics(ctx context.Context, params GetAnalyticsParams) (AnalyticsReport, error) {
+	if err := params.Validate(); err != nil {
+		return AnalyticsReport{}, err
+	}
+	return s.Analytics.GetAnalytics(ctx, params)
+}
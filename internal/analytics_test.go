@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAnalyticsBuckets(t *testing.T) {
+	hour := func(value string) time.Time {
+		bucket, _ := time.Parse(time.RFC3339, value)
+		return bucket
+	}
+	tests := []struct {
+		name      string
+		to        time.Time
+		window    time.Duration
+		wantCount int
+		wantFirst time.Time
+		wantLast  time.Time
+	}{
+		{name: "aligned", to: hour("2025-12-15T10:00:00Z"), window: time.Hour, wantCount: 2, wantFirst: hour("2025-12-15T10:00:00Z"), wantLast: hour("2025-12-15T09:00:00Z")},
+		{name: "not aligned", to: hour("2025-12-15T10:05:00Z"), window: time.Hour, wantCount: 2, wantFirst: hour("2025-12-15T10:00:00Z"), wantLast: hour("2025-12-15T09:00:00Z")},
+		{name: "not aligned day", to: hour("2025-12-15T10:05:00Z"), window: 24 * time.Hour, wantCount: 25, wantFirst: hour("2025-12-15T10:00:00Z"), wantLast: hour("2025-12-14T10:00:00Z")},
+		{name: "not aligned window", to: hour("2025-12-15T10:05:00Z"), window: 90 * time.Minute, wantCount: 3, wantFirst: hour("2025-12-15T10:00:00Z"), wantLast: hour("2025-12-15T08:00:00Z")},
+		{name: "local time", to: hour("2025-12-15T17:05:00+07:00"), window: 2 * time.Hour, wantCount: 3, wantFirst: hour("2025-12-15T10:00:00Z"), wantLast: hour("2025-12-15T08:00:00Z")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buckets := AnalyticsBuckets(tt.to, tt.window)
+			if len(buckets) != tt.wantCount {
+				t.Fatalf("AnalyticsBuckets() = %d buckets, want %d", len(buckets), tt.wantCount)
+			}
+			if !buckets[0].Equal(tt.wantFirst) || !buckets[len(buckets)-1].Equal(tt.wantLast) {
+				t.Errorf("AnalyticsBuckets() = %s to %s, want %s to %s", buckets[0], buckets[len(buckets)-1], tt.wantFirst, tt.wantLast)
+			}
+			if !slices.IsSortedFunc(buckets, func(a, b time.Time) int { return b.Compare(a) }) {
+				t.Errorf("AnalyticsBuckets() = %v, want newest first", buckets)
+			}
+		})
+	}
+}
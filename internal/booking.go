@@ -0,0 +1,240 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"log"
+	"net/mail"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrBookingNotFound  = errors.New("booking is not found")
+	ErrBookingExists    = errors.New("booking locator already exists")
+	ErrSeatsUnavailable = errors.New("seats are not available")
+)
+
+const (
+	MaxPassengerPerBooking = 9
+	locatorLength          = 6
+	locatorCharset         = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // without I, O, 0 and 1 which are easily mistaken
+)
+
+var (
+	passengerNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z '\-]*$`)
+	phonePattern         = regexp.MustCompile(`^\+?[0-9]{8,15}$`)
+	passengerTitles      = []string{"MR", "MRS", "MS", "MSTR", "MISS"}
+)
+
+type BookingStatus string
+
+const (
+	BookingHeld      BookingStatus = "held"
+	BookingCancelled BookingStatus = "cancelled"
+)
+
+type Passenger struct {
+	Title       string `json:"title" validate:"required,oneof=MR MRS MS MSTR MISS"`
+	FirstName   string `json:"first_name" validate:"required"`
+	LastName    string `json:"last_name" validate:"required"`
+	DateOfBirth string `json:"date_of_birth" validate:"required"` // 2006-01-02
+	Nationality string `json:"nationality" validate:"omitempty,len=2"`
+	DocumentID  string `json:"document_id" validate:"omitempty"` // passport or national ID
+}
+
+func (p Passenger) Validate() error {
+	if !slices.Contains(passengerTitles, strings.ToUpper(p.Title)) {
+		return errors.New("passenger title is invalid")
+	}
+	if !passengerNamePattern.MatchString(p.FirstName) || !passengerNamePattern.MatchString(p.LastName) {
+		return errors.New("passenger name is invalid")
+	}
+	dateOfBirth, err := time.Parse("2006-01-02", p.DateOfBirth)
+	if err != nil || dateOfBirth.After(time.Now()) {
+		return errors.New("passenger date of birth is invalid")
+	}
+	if p.Nationality != "" && len(p.Nationality) != 2 {
+		return errors.New("passenger nationality is invalid")
+	}
+	return nil
+}
+
+type Contact struct {
+	Email string `json:"email" validate:"required,email"`
+	Phone string `json:"phone" validate:"required"`
+}
+
+func (c Contact) Validate() error {
+	if _, err := mail.ParseAddress(c.Email); err != nil {
+		return errors.New("contact email is invalid")
+	}
+	if !phonePattern.MatchString(c.Phone) {
+		return errors.New("contact phone is invalid")
+	}
+	return nil
+}
+
+type CreateBookingParams struct {
+	FlightID   string      `json:"flight_id" validate:"required"`
+	FareID     string      `json:"fare_id" validate:"omitempty"` // default to flight main fare
+	Passengers []Passenger `json:"passengers" validate:"required"`
+	Contact    Contact     `json:"contact" validate:"required"`
+}
+
+func (p CreateBookingParams) Validate() error {
+	if p.FlightID == "" {
+		return errors.New("flight id must be filled")
+	}
+	if len(p.Passengers) == 0 || len(p.Passengers) > MaxPassengerPerBooking {
+		return errors.New("passengers must be between 1 and 9")
+	}
+	for _, passenger := range p.Passengers {
+		if err := passenger.Validate(); err != nil {
+			return err
+		}
+	}
+	return p.Contact.Validate()
+}
+
+type Booking struct {
+	Locator       string        `json:"locator"`
+	TenantID      string        `json:"tenant_id,omitempty"` // tenant who made the booking, empty when authentication is disabled
+	Status        BookingStatus `json:"status"`
+	Flight        Flight        `json:"flight"`
+	Fare          Fare          `json:"fare"`
+	Passengers    []Passenger   `json:"passengers"`
+	Contact       Contact       `json:"contact"`
+	TotalPrice    Price         `json:"total_price"`     // selling price after price rule
+	NetTotalPrice Price         `json:"net_total_price"` // provider price
+	HoldReference string        `json:"hold_reference"`  // provider side hold
+	CreatedAt     string        `json:"created_at"`
+	ExpiredAt     string        `json:"expired_at"`
+}
+
+func generateLocator() string {
+	random := make([]byte, locatorLength)
+	_, _ = rand.Read(random)
+	locator := make([]byte, locatorLength)
+	for i, b := range random {
+		locator[i] = locatorCharset[int(b)%len(locatorCharset)]
+	}
+	return string(locator)
+}
+
+// mainFare return the fare matching the flight price, the fare shown on search result
+func mainFare(flight Flight) (Fare, bool) {
+	for _, fare := range flight.Fares {
+		if fare.CabinClass == flight.CabinClass && fare.Price == flight.Price {
+			return fare, true
+		}
+	}
+	if len(flight.Fares) > 0 {
+		return flight.Fares[0], true
+	}
+	return Fare{}, false
+}
+
+func (s *InternalService) CreateBooking(ctx context.Context, params CreateBookingParams) (Booking, error) {
+	if !allowedProvider(ctx, ProviderOfFlightID(params.FlightID)) {
+		return Booking{}, ErrFlightNotFound
+	}
+	flight, err := s.CacheService.GetFlightByID(ctx, params.FlightID)
+	if err != nil {
+		return Booking{}, err
+	}
+	flight = s.priceFlight(ctx, flight)
+
+	fare, exist := mainFare(flight)
+	if params.FareID != "" {
+		fare, exist = findFare(flight, params.FareID)
+	}
+	if !exist {
+		return Booking{}, ErrFareNotFound
+	}
+
+	searchParams := searchParamsOfFlight(flight)
+	holdReference, err := s.BookingProvider.HoldSeats(HoldSeatsParams{
+		Provider:      flight.Provider,
+		Origin:        searchParams.Origin,
+		Destination:   searchParams.Destination,
+		DepartureDate: searchParams.DepartureDate,
+		FlightNumber:  flight.FlightNumber,
+		BookingClass:  fare.BookingClass,
+		Seats:         int16(len(params.Passengers)),
+		TTL:           s.Cfg.BookingHoldTTL,
+	})
+	if err != nil {
+		return Booking{}, err
+	}
+
+	now := time.Now()
+	booking := Booking{
+		TenantID:      tenantID(ctx),
+		Status:        BookingHeld,
+		Flight:        flight,
+		Fare:          fare,
+		Passengers:    params.Passengers,
+		Contact:       params.Contact,
+		TotalPrice:    Price{Amount: fare.Price.Amount * len(params.Passengers), Currency: fare.Price.Currency},
+		NetTotalPrice: Price{Amount: fare.NetPrice.Amount * len(params.Passengers), Currency: fare.NetPrice.Currency},
+		HoldReference: holdReference,
+		CreatedAt:     now.Format(time.RFC3339),
+		ExpiredAt:     now.Add(s.Cfg.BookingHoldTTL).Format(time.RFC3339),
+	}
+	for i := range booking.Passengers {
+		booking.Passengers[i].Title = strings.ToUpper(booking.Passengers[i].Title)
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		booking.Locator = generateLocator()
+		err = s.CacheService.CreateBooking(ctx, booking, s.Cfg.BookingHoldTTL)
+		if !errors.Is(err, ErrBookingExists) {
+			break
+		}
+	}
+	if err != nil {
+		if releaseErr := s.BookingProvider.ReleaseSeats(flight.Provider, holdReference); releaseErr != nil {
+			log.Printf("[%s]fail to release seats, Err : %v\n", flight.ID, releaseErr)
+		}
+		return Booking{}, err
+	}
+	return booking, nil
+}
+
+// GetBooking hide bookings of other tenants as not found so a locator can not be probed
+func (s *InternalService) GetBooking(ctx context.Context, locator string) (Booking, error) {
+	booking, err := s.CacheService.GetBooking(ctx, strings.ToUpper(locator))
+	if err != nil {
+		return Booking{}, err
+	}
+	if booking.TenantID != tenantID(ctx) {
+		return Booking{}, ErrBookingNotFound
+	}
+	return booking, nil
+}
+
+func (s *InternalService) CancelBooking(ctx context.Context, locator string) (Booking, error) {
+	booking, err := s.GetBooking(ctx, locator)
+	if err != nil {
+		return Booking{}, err
+	}
+	if booking.Status == BookingCancelled {
+		return booking, nil
+	}
+
+	err = s.BookingProvider.ReleaseSeats(booking.Flight.Provider, booking.HoldReference)
+	if err != nil {
+		log.Printf("[%s]fail to release seats, Err : %v\n", booking.Locator, err)
+	}
+
+	booking.Status = BookingCancelled
+	err = s.CacheService.UpdateBooking(ctx, booking)
+	if err != nil {
+		return Booking{}, err
+	}
+	return booking, nil
+}
@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"kevinjuniawan/bookcabin/config"
+)
+
+// bookingCache keep bookings by locator like the cache backends
+type bookingCache struct {
+	ICache
+	bookings map[string]Booking
+}
+
+func (c *bookingCache) GetBooking(ctx context.Context, locator string) (Booking, error) {
+	booking, exist := c.bookings[locator]
+	if !exist {
+		return Booking{}, ErrBookingNotFound
+	}
+	return booking, nil
+}
+
+func (c *bookingCache) UpdateBooking(ctx context.Context, booking Booking) error {
+	c.bookings[booking.Locator] = booking
+	return nil
+}
+
+// seatProvider count released holds
+type seatProvider struct {
+	released int
+}
+
+func (p *seatProvider) HoldSeats(params HoldSeatsParams) (string, error) {
+	return "HOLD1", nil
+}
+
+func (p *seatProvider) ReleaseSeats(provider string, holdReference string) error {
+	p.released++
+	return nil
+}
+
+func TestBookingTenant(t *testing.T) {
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		wantErr      error
+		wantReleased int
+	}{
+		{name: "owner tenant", ctx: WithTenant(context.Background(), Tenant{ID: "acme"}), wantReleased: 1},
+		{name: "other tenant", ctx: WithTenant(context.Background(), Tenant{ID: "globex"}), wantErr: ErrBookingNotFound},
+		{name: "without tenant", ctx: context.Background(), wantErr: ErrBookingNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := &bookingCache{bookings: map[string]Booking{
+				"ABC234": {Locator: "ABC234", TenantID: "acme", Status: BookingHeld, HoldReference: "HOLD1"},
+			}}
+			provider := &seatProvider{}
+			s := NewInternalService(InternalServiceParams{CacheService: cache, BookingProvider: provider, Cfg: config.Config{}})
+
+			booking, err := s.GetBooking(tt.ctx, "abc234")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetBooking() Err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && booking.Locator != "ABC234" {
+				t.Errorf("GetBooking() locator = %s, want ABC234", booking.Locator)
+			}
+
+			_, err = s.CancelBooking(tt.ctx, "ABC234")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CancelBooking() Err = %v, want %v", err, tt.wantErr)
+			}
+			wantStatus := BookingHeld
+			if tt.wantErr == nil {
+				wantStatus = BookingCancelled
+			}
+			if status := cache.bookings["ABC234"].Status; status != wantStatus {
+				t.Errorf("booking status = %s, want %s", status, wantStatus)
+			}
+			if provider.released != tt.wantReleased {
+				t.Errorf("released holds = %d, want %d", provider.released, tt.wantReleased)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package internal
+
+import "sync"
+
+type fetchCall struct {
+	wg     sync.WaitGroup
+	result FlightDataResponse
+	err    error
+}
+
+// fetchGroup share one provider fetch between concurrent searches with the same route key
+type fetchGroup struct {
+	mu    sync.Mutex
+	calls map[string]*fetchCall
+}
+
+func newFetchGroup() *fetchGroup {
+	return &fetchGroup{calls: map[string]*fetchCall{}}
+}
+
+// do run fetch once for every concurrent caller of the same key, every caller receive its own copy of the result
+// and coalesced is true when the result come from another caller fetch
+func (g *fetchGroup) do(key string, fetch func() (FlightDataResponse, error)) (result FlightDataResponse, coalesced bool, err error) {
+	g.mu.Lock()
+	if call, exist := g.calls[key]; exist {
+		g.mu.Unlock()
+		call.wg.Wait()
+		return copyFlightData(call.result), true, call.err
+	}
+	call := &fetchCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		call.wg.Done()
+	}()
+
+	call.result, call.err = fetch()
+	return copyFlightData(call.result), false, call.err
+}
+
+func copyFlightData(data FlightDataResponse) FlightDataResponse {
+	flights := make([]Flight, len(data.Flights))
+	for i, flight := range data.Flights {
+		flights[i] = flight.Clone()
+	}
+	data.Flights = flights
+	return data
+}
@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	HealthStatusOK       = "ok"
+	HealthStatusDegraded = "degraded"
+)
+
+type HealthState struct {
+	Status           string                `json:"status"`
+	Cache            string                `json:"cache"` // up, down
+	Error            string                `json:"error,omitempty"`
+	CacheConsistency CacheConsistencyStats `json:"cache_consistency"`
+}
+
+// cacheBreaker skip the cache for a while after it fail, searches are answered by providers instead of waiting on the cache
+type cacheBreaker struct {
+	mu        sync.Mutex
+	bypassFor time.Duration
+	openUntil time.Time
+}
+
+func newCacheBreaker(bypassFor time.Duration) *cacheBreaker {
+	return &cacheBreaker{bypassFor: bypassFor}
+}
+
+func (b *cacheBreaker) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return time.Now().Before(b.openUntil)
+}
+
+func (b *cacheBreaker) trip() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.openUntil = time.Now().Add(b.bypassFor)
+}
+
+func (b *cacheBreaker) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.openUntil = time.Time{}
+}
+
+func (s *InternalService) cacheFailed(action string, err error) {
+	log.Printf("[%s]fail to access cache, bypass cache for %s, Err : %v\n", action, s.Cfg.CacheBypassTime, err)
+	s.cacheBreaker.trip()
+}
+
+// Health ping the cache, service is degraded but still answer searches when the cache is down
+func (s *InternalService) Health(ctx context.Context) HealthState {
+	if err := s.CacheService.Ping(ctx); err != nil {
+		s.cacheFailed("health", err)
+		return HealthState{Status: HealthStatusDegraded, Cache: "down", Error: err.Error(), CacheConsistency: s.CacheService.ConsistencyStats()}
+	}
+	s.cacheBreaker.reset()
+	return HealthState{Status: HealthStatusOK, Cache: "up", CacheConsistency: s.CacheService.ConsistencyStats()}
+}
@@ -1,6 +1,12 @@
 package internal
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
 
 type Airline struct {
 	Code string `json:"code" validate:"required"`
@@ -31,18 +37,173 @@ func (p Price) AmountInIDR() int {
 	return p.Amount * 15000 //Infer Other than IDR is USD & fixed rate 1 USD = 15000 IDR
 }
 
+// In convert the price with the same fixed rate as AmountInIDR, USD is rounded to the nearest dollar
+func (p Price) In(currency string) Price {
+	if p.Currency == currency {
+		return p
+	}
+	if currency == "IDR" {
+		return Price{Amount: p.AmountInIDR(), Currency: "IDR"}
+	}
+	return Price{Amount: int(math.Round(float64(p.Amount) / 15000)), Currency: currency}
+}
+
+type BaggageType string
+
+const (
+	BaggageIncluded     BaggageType = "included"
+	BaggagePaid         BaggageType = "paid"
+	BaggageNotAvailable BaggageType = "not_available"
+)
+
+type BaggageAllowance struct {
+	Type     BaggageType `json:"type" validate:"required,oneof=included paid not_available"`
+	Pieces   int8        `json:"pieces" validate:"min=0"`
+	WeightKg int16       `json:"weight_kg" validate:"min=0"` // 0 means weight is not specified by provider
+}
+
+func (b BaggageAllowance) IsIncluded() bool {
+	return b.Type == BaggageIncluded
+}
+
 type Bag struct {
-	CarryOn string `json:"carry_on" validate:"required"`
-	Checked string `json:"checked" validate:"required"`
+	CarryOn BaggageAllowance `json:"carry_on" validate:"required"`
+	Checked BaggageAllowance `json:"checked" validate:"required"`
+}
+
+type Amenity string
+
+const (
+	AmenityWifi          Amenity = "wifi"
+	AmenityMeal          Amenity = "meal"
+	AmenitySnack         Amenity = "snack"
+	AmenityBeverage      Amenity = "beverage"
+	AmenityEntertainment Amenity = "entertainment"
+	AmenityPowerOutlet   Amenity = "power_outlet"
+)
+
+var Amenities = []Amenity{AmenityWifi, AmenityMeal, AmenitySnack, AmenityBeverage, AmenityEntertainment, AmenityPowerOutlet}
+
+func (a Amenity) IsValid() bool {
+	for _, amenity := range Amenities {
+		if a == amenity {
+			return true
+		}
+	}
+	return false
+}
+
+type AircraftBody string
+
+const (
+	NarrowBody AircraftBody = "narrow_body"
+	WideBody   AircraftBody = "wide_body"
+)
+
+type Aircraft struct {
+	Model  string       `json:"model" validate:"required"`
+	Family string       `json:"family,omitempty" validate:"omitempty"`
+	Body   AircraftBody `json:"body,omitempty" validate:"omitempty,oneof=narrow_body wide_body"`
+}
+
+// Match compare the given type with aircraft model or family, e.g. "Boeing 737-800" or "737"
+func (a Aircraft) Match(aircraftType string) bool {
+	return strings.EqualFold(a.Model, aircraftType) || (a.Family != "" && strings.EqualFold(a.Family, aircraftType))
+}
+
+type Layover struct {
+	Airport     string `json:"airport" validate:"required"`
+	TotalMinute int    `json:"total_minute" validate:"min=0"`
+}
+
+type Segment struct {
+	Airline       Airline   `json:"airline" validate:"required"`
+	FlightNumber  string    `json:"flight_number" validate:"omitempty"`
+	Departure     Airport   `json:"departure" validate:"required"`
+	Arrival       Airport   `json:"arrival" validate:"required"`
+	Duration      *Duration `json:"duration,omitempty" validate:"omitempty"`
+	Aircraft      *Aircraft `json:"aircraft,omitempty" validate:"omitempty"`
+	LayoverMinute int       `json:"layover_minute,omitempty" validate:"min=0"` // layover before this segment departs
+	IsPartial     bool      `json:"is_partial"`                                // provider only send connection airport, leg detail is unknown
 }
 
 type Class string
 
 const (
-	EconomyClass  Class = "economy"
-	BusinessClass Class = "business"
+	EconomyClass        Class = "economy"
+	PremiumEconomyClass Class = "premium_economy"
+	BusinessClass       Class = "business"
+	FirstClass          Class = "first"
+)
+
+var mapClassToRank = map[Class]int{
+	EconomyClass:        1,
+	PremiumEconomyClass: 2,
+	BusinessClass:       3,
+	FirstClass:          4,
+}
+
+func (c Class) IsValid() bool {
+	_, exist := mapClassToRank[c]
+	return exist
+}
+
+// IsAtLeast report whether cabin c is the same or better than the other cabin
+func (c Class) IsAtLeast(other Class) bool {
+	return mapClassToRank[c] >= mapClassToRank[other]
+}
+
+// Accept report whether cabin c satisfy the requested cabin, orBetter also accept higher cabin
+func (c Class) Accept(requested Class, orBetter bool) bool {
+	if orBetter {
+		return c.IsAtLeast(requested)
+	}
+	return c == requested
+}
+
+type FareFamily string
+
+const (
+	FareFamilyEconomyLite    FareFamily = "economy_lite"
+	FareFamilyEconomyClassic FareFamily = "economy_classic"
+	FareFamilyEconomyFlex    FareFamily = "economy_flex"
+	FareFamilyPremiumEconomy FareFamily = "premium_economy"
+	FareFamilyBusinessSaver  FareFamily = "business_saver"
+	FareFamilyBusinessFlex   FareFamily = "business_flex"
+	FareFamilyFirst          FareFamily = "first"
 )
 
+var FareFamilies = []FareFamily{FareFamilyEconomyLite, FareFamilyEconomyClassic, FareFamilyEconomyFlex, FareFamilyPremiumEconomy, FareFamilyBusinessSaver, FareFamilyBusinessFlex, FareFamilyFirst}
+
+func (f FareFamily) IsValid() bool {
+	for _, family := range FareFamilies {
+		if f == family {
+			return true
+		}
+	}
+	return false
+}
+
+type FareConditions struct {
+	Refundable     bool `json:"refundable"`
+	RefundFee      int  `json:"refund_fee"` // in IDR
+	Changeable     bool `json:"changeable"`
+	ChangeFee      int  `json:"change_fee"` // in IDR
+	SeatSelection  bool `json:"seat_selection"`
+	CheckedBaggage bool `json:"checked_baggage"`
+}
+
+type Fare struct {
+	ID             string         `json:"id" validate:"required"`
+	BookingClass   string         `json:"booking_class" validate:"required"` // RBD, e.g. Y, K, C
+	FareFamily     FareFamily     `json:"fare_family" validate:"required"`
+	CabinClass     Class          `json:"cabin_class" validate:"required"`
+	Price          Price          `json:"price" validate:"required"`
+	NetPrice       *Price         `json:"net_price,omitempty" validate:"omitempty"` // provider price before price rule
+	AvailableSeats int16          `json:"available_seats" validate:"min=0"`
+	Conditions     FareConditions `json:"conditions" validate:"required"`
+}
+
 type SearchResponse struct {
 	Metadata Metadata `json:"metadata" validate:"required"`
 	Flights  []Flight `json:"flights" validate:"required"`
@@ -53,24 +214,92 @@ type Metadata struct {
 	SucceededProvider int16 `json:"succeeded_provider" validate:"required"`
 	SearchTimeMs      int32 `json:"search_time_ms" validate:"required"`
 	IsCache           bool
+	IsCoalesced       bool
+	IsCacheBypassed   bool
 }
 
 type Flight struct {
-	ID             string   `json:"id" validate:"required"`
-	Provider       string   `json:"provider" validate:"required"`
-	Airline        Airline  `json:"airline" validate:"required"`
-	FlightNumber   string   `json:"flight_number" validate:"required"`
-	Departure      Airport  `json:"departure" validate:"required"`
-	Arrival        Airport  `json:"arrival" validate:"required"`
-	Duration       Duration `json:"duration" validate:"required"`
-	Stops          int8     `json:"stops" validate:"min=0"`
-	Price          Price    `json:"price" validate:"required"`
-	AvailableSeats int16    `json:"available_seats" validate:"min=0"`
-	CabinClass     Class    `json:"cabin_class" validate:"required,oneof=economy business"`
-	Aircraft       *string  `json:"aircraft" validate:"omitempty"`
-	Amenities      []string `json:"amenities" validate:"omitempty"`
-	Baggage        Bag      `json:"baggage" validate:"required"`
-	Layover        int      `json:"layover,omitempty" validate:"min=0"`
+	ID              string           `json:"id" validate:"required"`
+	Provider        string           `json:"provider" validate:"required"`
+	Airline         Airline          `json:"airline" validate:"required"`
+	FlightNumber    string           `json:"flight_number" validate:"required"`
+	Departure       Airport          `json:"departure" validate:"required"`
+	Arrival         Airport          `json:"arrival" validate:"required"`
+	Duration        Duration         `json:"duration" validate:"required"`
+	Stops           int8             `json:"stops" validate:"min=0"`
+	Price           Price            `json:"price" validate:"required"`
+	NetPrice        *Price           `json:"net_price,omitempty" validate:"omitempty"`     // provider price before price rule
+	PriceRuleID     string           `json:"price_rule_id,omitempty" validate:"omitempty"` // rule applied on the flight price
+	AvailableSeats  int16            `json:"available_seats" validate:"min=0"`
+	CabinClass      Class            `json:"cabin_class" validate:"required,oneof=economy premium_economy business first"`
+	Aircraft        *Aircraft        `json:"aircraft" validate:"omitempty"`
+	Amenities       []Amenity        `json:"amenities" validate:"omitempty"`
+	Baggage         Bag              `json:"baggage" validate:"required"`
+	Layover         int              `json:"layover,omitempty" validate:"min=0"`
+	Layovers        []Layover        `json:"layovers,omitempty" validate:"omitempty"`
+	Segments        []Segment        `json:"segments" validate:"omitempty"`
+	Fares           []Fare           `json:"fares" validate:"omitempty"`
+	Score           *ScoreBreakdown  `json:"score,omitempty" validate:"omitempty"`
+	Personalization *Personalization `json:"personalization,omitempty" validate:"omitempty"`
+}
+
+// Clone copy the slices a caller may modify so a cached or shared flight is never changed, pointer fields are shared
+// since they are replaced rather than modified
+func (f Flight) Clone() Flight {
+	f.Amenities = slices.Clone(f.Amenities)
+	f.Layovers = slices.Clone(f.Layovers)
+	f.Segments = slices.Clone(f.Segments)
+	f.Fares = slices.Clone(f.Fares)
+	return f
+}
+
+// InCurrency convert the flight and fare prices, fares are copied so cached flights are untouched
+func (f Flight) InCurrency(currency string) Flight {
+	f.Price = f.Price.In(currency)
+	f.NetPrice = inCurrency(f.NetPrice, currency)
+	fares := make([]Fare, len(f.Fares))
+	for i, fare := range f.Fares {
+		fare.Price = fare.Price.In(currency)
+		fare.NetPrice = inCurrency(fare.NetPrice, currency)
+		fares[i] = fare
+	}
+	f.Fares = fares
+	return f
+}
+
+func inCurrency(price *Price, currency string) *Price {
+	if price == nil {
+		return nil
+	}
+	converted := price.In(currency)
+	return &converted
+}
+
+func (f Flight) HasFareFamily(families []FareFamily) bool {
+	for _, fare := range f.Fares {
+		for _, family := range families {
+			if fare.FareFamily == family {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (f Flight) HasAmenities(amenities []Amenity) bool {
+	for _, required := range amenities {
+		found := false
+		for _, amenity := range f.Amenities {
+			if amenity == required {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
 }
 
 type GetFlightsParams struct {
@@ -80,8 +309,29 @@ type GetFlightsParams struct {
 	Passenger     int16               `json:"passenger" validate:"required"`
 	ReturnDate    *string             `json:"return_date" validate:"omitempty"`
 	SortType      SortType            `json:"sort_type" validate:"required"`
+	Sort          []SortKey           `json:"sort" validate:"omitempty"` // take precedence over SortType
 	CabinClass    string              `json:"cabin_class" validate:"omitempty"`
+	CabinOrBetter bool                `json:"cabin_or_better" validate:"omitempty"` // also return higher cabin than CabinClass
 	Filter        *FilterFlightParams `json:"filter"`
+	Scoring       *ScoringParams      `json:"scoring"`
+	ProfileID     string              `json:"profile_id" validate:"omitempty"`
+}
+
+func (p GetFlightsParams) SortKeys() []SortKey {
+	if len(p.Sort) != 0 {
+		return p.Sort
+	}
+	return []SortKey{MapSortTypeToSortKey[p.SortType]}
+}
+
+// RouteKey identify the provider result of a search, it hold every parameter changing the flights returned by
+// providers so searches with the same key share the same flights. Sort, filter, scoring and profile are applied after
+func (p GetFlightsParams) RouteKey() string {
+	returnDate := "-"
+	if p.ReturnDate != nil && *p.ReturnDate != "" {
+		returnDate = *p.ReturnDate
+	}
+	return fmt.Sprintf("%s:%s:%s:%s:%d:%s:%t", p.Origin, p.Destination, p.DepartureDate, returnDate, max(p.Passenger, 1), p.CabinClass, p.CabinOrBetter)
 }
 
 func (p GetFlightsParams) Validate() error {
@@ -97,7 +347,7 @@ func (p GetFlightsParams) Validate() error {
 		return errors.New("departure date must be filled")
 	}
 
-	if p.CabinClass == "" || (p.CabinClass != string(BusinessClass) && p.CabinClass != string(EconomyClass)) {
+	if !Class(p.CabinClass).IsValid() {
 		return errors.New("cabin class is invalid")
 	}
 
@@ -105,6 +355,24 @@ func (p GetFlightsParams) Validate() error {
 		return errors.New("sort type is invalid")
 	}
 
+	if len(p.Sort) > MaxSortKeys {
+		return errors.New("too many sort keys")
+	}
+	for _, key := range p.Sort {
+		if !key.IsValid() {
+			return errors.New("sort key " + string(key.Field) + " is invalid")
+		}
+	}
+
+	if p.Scoring != nil {
+		if p.Scoring.Weights != nil && !p.Scoring.Weights.IsValid() {
+			return errors.New("scoring weights is invalid")
+		}
+		if p.Scoring.PreferredDeparture != nil && !p.Scoring.PreferredDeparture.IsValid() {
+			return errors.New("scoring preferred departure is invalid")
+		}
+	}
+
 	if p.Filter != nil {
 		if p.Filter.TimeRange != nil && (p.Filter.TimeRange.Type == "" || p.Filter.TimeRange.From == "" || p.Filter.TimeRange.To == "") {
 			return errors.New("time range type, from, and to is invalid")
@@ -115,10 +383,48 @@ func (p GetFlightsParams) Validate() error {
 		if p.Filter.Stops != nil && (*p.Filter.Stops < 0) {
 			return errors.New("stops is invalid")
 		}
+		for _, amenity := range p.Filter.Amenities {
+			if !amenity.IsValid() {
+				return errors.New("amenity " + string(amenity) + " is invalid")
+			}
+		}
+		if p.Filter.MaxDuration != nil && *p.Filter.MaxDuration <= 0 {
+			return errors.New("max duration is invalid")
+		}
+		if p.Filter.MaxStops != nil && *p.Filter.MaxStops < 0 {
+			return errors.New("max stops is invalid")
+		}
+		if p.Filter.Layover != nil {
+			layover := p.Filter.Layover
+			if (layover.MinMinute != nil && *layover.MinMinute < 0) || (layover.MaxMinute != nil && *layover.MaxMinute < 0) ||
+				(layover.MinMinute != nil && layover.MaxMinute != nil && *layover.MinMinute > *layover.MaxMinute) {
+				return errors.New("min and max layover is invalid")
+			}
+		}
+		for _, family := range p.Filter.FareFamilies {
+			if !family.IsValid() {
+				return errors.New("fare family " + string(family) + " is invalid")
+			}
+		}
+		if p.Filter.AircraftBody != "" && p.Filter.AircraftBody != NarrowBody && p.Filter.AircraftBody != WideBody {
+			return errors.New("aircraft body is invalid")
+		}
 	}
 	return nil
 }
 
+type GetFlightDetailParams struct {
+	Origin        string `json:"origin" validate:"omitempty"`
+	Destination   string `json:"destination" validate:"omitempty"`
+	DepartureDate string `json:"departure_date" validate:"omitempty"`
+	CabinClass    string `json:"cabin_class" validate:"omitempty"`
+}
+
+// CanQueryProvider report whether the route is known so flight missing from cache can be queried to the provider
+func (p GetFlightDetailParams) CanQueryProvider() bool {
+	return p.Origin != "" && p.Destination != "" && p.DepartureDate != ""
+}
+
 type SortType int
 
 const (
@@ -131,6 +437,56 @@ const (
 	SortArrivalType
 )
 
+type SortField string
+
+const (
+	SortFieldBestValue SortField = "best_value"
+	SortFieldPrice     SortField = "price"
+	SortFieldDuration  SortField = "duration"
+	SortFieldDeparture SortField = "departure"
+	SortFieldArrival   SortField = "arrival"
+	SortFieldStops     SortField = "stops"
+	SortFieldLayover   SortField = "layover"
+)
+
+var SortFields = []SortField{SortFieldBestValue, SortFieldPrice, SortFieldDuration, SortFieldDeparture, SortFieldArrival, SortFieldStops, SortFieldLayover}
+
+type SortDirection string
+
+const (
+	SortAscending  SortDirection = "asc"
+	SortDescending SortDirection = "desc"
+)
+
+type SortKey struct {
+	Field     SortField     `json:"field" validate:"required"`
+	Direction SortDirection `json:"direction" validate:"omitempty,oneof=asc desc"`
+}
+
+func (k SortKey) IsValid() bool {
+	if k.Direction != "" && k.Direction != SortAscending && k.Direction != SortDescending {
+		return false
+	}
+	for _, field := range SortFields {
+		if k.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+const MaxSortKeys = 5
+
+var MapSortTypeToSortKey = map[SortType]SortKey{
+	SortBestValueType:        {Field: SortFieldBestValue, Direction: SortAscending},
+	SortLowestPriceType:      {Field: SortFieldPrice, Direction: SortAscending},
+	SortHighestPriceType:     {Field: SortFieldPrice, Direction: SortDescending},
+	SortShortestDurationType: {Field: SortFieldDuration, Direction: SortAscending},
+	SortLongestDurationType:  {Field: SortFieldDuration, Direction: SortDescending},
+	SortDepartureType:        {Field: SortFieldDeparture, Direction: SortAscending},
+	SortArrivalType:          {Field: SortFieldArrival, Direction: SortAscending},
+}
+
 type FilterFlightTimeType string
 
 const (
@@ -139,10 +495,31 @@ const (
 )
 
 type FilterFlightParams struct {
-	Airline   []string                 `json:"airline" validate:"omitempty"`
-	Price     *FilterFlightPriceParams `json:"price" validate:"omitempty"`
-	Stops     *int8                    `json:"stops" validate:"omitempty"`
-	TimeRange *FilterFlightTimeParams  `json:"time_range" validate:"omitempty"`
+	Airline                []string                      `json:"airline" validate:"omitempty"`
+	Price                  *FilterFlightPriceParams      `json:"price" validate:"omitempty"`
+	Stops                  *int8                         `json:"stops" validate:"omitempty"`
+	TimeRange              *FilterFlightTimeParams       `json:"time_range" validate:"omitempty"`
+	CheckedBaggageIncluded bool                          `json:"checked_baggage_included" validate:"omitempty"`
+	Amenities              []Amenity                     `json:"amenities" validate:"omitempty"`
+	Aircraft               []string                      `json:"aircraft" validate:"omitempty"`
+	AircraftBody           AircraftBody                  `json:"aircraft_body" validate:"omitempty"`
+	MaxDuration            *int16                        `json:"max_duration" validate:"omitempty"` // in minute
+	MaxStops               *int8                         `json:"max_stops" validate:"omitempty"`
+	Layover                *FilterFlightLayoverParams    `json:"layover" validate:"omitempty"`
+	ConnectionAirports     *FilterFlightConnectionParams `json:"connection_airports" validate:"omitempty"`
+	FareFamilies           []FareFamily                  `json:"fare_families" validate:"omitempty"`
+}
+
+// Layover range is applied on every stop of the flight, direct flight always pass
+type FilterFlightLayoverParams struct {
+	MinMinute *int `json:"min_minute" validate:"omitempty"`
+	MaxMinute *int `json:"max_minute" validate:"omitempty"`
+}
+
+// Include only allow connection through listed airports, Exclude drop flight connecting through listed airports
+type FilterFlightConnectionParams struct {
+	Include []string `json:"include" validate:"omitempty"`
+	Exclude []string `json:"exclude" validate:"omitempty"`
 }
 
 type FilterFlightPriceParams struct {
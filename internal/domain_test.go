@@ -0,0 +1,32 @@
+package internal
+
+import "testing"
+
+func TestClassAccept(t *testing.T) {
+	tests := []struct {
+		cabin     Class
+		requested Class
+		orBetter  bool
+		want      bool
+	}{
+		{cabin: EconomyClass, requested: EconomyClass, want: true},
+		{cabin: BusinessClass, requested: EconomyClass, want: false},
+		{cabin: BusinessClass, requested: EconomyClass, orBetter: true, want: true},
+		{cabin: PremiumEconomyClass, requested: EconomyClass, orBetter: true, want: true},
+		{cabin: EconomyClass, requested: PremiumEconomyClass, orBetter: true, want: false},
+		{cabin: FirstClass, requested: BusinessClass, orBetter: true, want: true},
+		{cabin: BusinessClass, requested: FirstClass, orBetter: true, want: false},
+		{cabin: FirstClass, requested: FirstClass, orBetter: true, want: true},
+	}
+	for _, tt := range tests {
+		name := string(tt.cabin) + " for " + string(tt.requested)
+		if tt.orBetter {
+			name += " or better"
+		}
+		t.Run(name, func(t *testing.T) {
+			if got := tt.cabin.Accept(tt.requested, tt.orBetter); got != tt.want {
+				t.Errorf("Accept() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"time"
+)
+
+var ErrTravellerProfileNotFound = errors.New("traveller profile is not found")
+
+const (
+	PreferenceMatchPreferredAirline   = "preferred_airline"
+	PreferenceMatchLoyaltyProgram     = "loyalty_program"
+	PreferenceMatchHomeAirport        = "home_airport"
+	PreferenceMatchPreferredDeparture = "preferred_departure"
+	PreferenceMatchAvoidedAirline     = "avoided_airline"
+)
+
+type TravellerProfile struct {
+	ID                 string           `json:"id" validate:"required"`
+	TenantID           string           `json:"tenant_id,omitempty"`                     // owner of the profile, set from the authenticated tenant
+	PreferredAirlines  []string         `json:"preferred_airlines" validate:"omitempty"` // airline code, e.g. GarudaIndonesia
+	AvoidedAirlines    []string         `json:"avoided_airlines" validate:"omitempty"`
+	HomeAirport        string           `json:"home_airport" validate:"omitempty"`
+	PreferredDeparture *DepartureWindow `json:"preferred_departure" validate:"omitempty"`
+	LoyaltyPrograms    []string         `json:"loyalty_programs" validate:"omitempty"` // airline code of the program
+}
+
+func (p TravellerProfile) Validate() error {
+	if p.ID == "" {
+		return errors.New("profile id must be filled")
+	}
+	if p.PreferredDeparture != nil && !p.PreferredDeparture.IsValid() {
+		return errors.New("preferred departure is invalid")
+	}
+	for _, airline := range p.PreferredAirlines {
+		if slices.Contains(p.AvoidedAirlines, airline) {
+			return errors.New("airline " + airline + " is both preferred and avoided")
+		}
+	}
+	return nil
+}
+
+type Personalization struct {
+	Boost   int      `json:"boost"`
+	Matched []string `json:"matched"`
+}
+
+func (p TravellerProfile) Personalize(flight Flight) *Personalization {
+	personalization := &Personalization{Matched: []string{}}
+	if slices.Contains(p.AvoidedAirlines, flight.Airline.Code) {
+		personalization.Boost--
+		personalization.Matched = append(personalization.Matched, PreferenceMatchAvoidedAirline)
+		return personalization
+	}
+	if slices.Contains(p.PreferredAirlines, flight.Airline.Code) {
+		personalization.Boost++
+		personalization.Matched = append(personalization.Matched, PreferenceMatchPreferredAirline)
+	}
+	if slices.Contains(p.LoyaltyPrograms, flight.Airline.Code) {
+		personalization.Boost++
+		personalization.Matched = append(personalization.Matched, PreferenceMatchLoyaltyProgram)
+	}
+	if p.HomeAirport != "" && flight.Departure.Airport == p.HomeAirport {
+		personalization.Boost++
+		personalization.Matched = append(personalization.Matched, PreferenceMatchHomeAirport)
+	}
+	if p.PreferredDeparture != nil {
+		departureTime, err := time.Parse(time.RFC3339, flight.Departure.Datetime)
+		if err == nil && departureTime.Hour() >= p.PreferredDeparture.FromHour && departureTime.Hour() <= p.PreferredDeparture.ToHour {
+			personalization.Boost++
+			personalization.Matched = append(personalization.Matched, PreferenceMatchPreferredDeparture)
+		}
+	}
+	if personalization.Boost == 0 {
+		return nil
+	}
+	return personalization
+}
+
+// rankByProfile move matching flights up and avoided airline down while keeping the base ordering between same boost
+func rankByProfile(flights []Flight, profile TravellerProfile) []Flight {
+	for i := range flights {
+		flights[i].Personalization = profile.Personalize(flights[i])
+	}
+	sort.SliceStable(flights, func(i, j int) bool {
+		return flights[i].Personalization.GetBoost() > flights[j].Personalization.GetBoost()
+	})
+	return flights
+}
+
+func (p *Personalization) GetBoost() int {
+	if p == nil {
+		return 0
+	}
+	return p.Boost
+}
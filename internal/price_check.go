@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrFlightNotFound = errors.New("flight is not found")
+	ErrFareNotFound   = errors.New("fare is not found")
+)
+
+type PriceCheckStatus string
+
+const (
+	PriceCheckConfirmed PriceCheckStatus = "confirmed"
+	PriceCheckChanged   PriceCheckStatus = "changed"
+	PriceCheckSoldOut   PriceCheckStatus = "sold_out"
+)
+
+type PriceCheckParams struct {
+	FareID    string `json:"fare_id" validate:"omitempty"` // default to flight main fare
+	Passenger int16  `json:"passenger" validate:"omitempty"`
+}
+
+type PriceCheckResult struct {
+	Status       PriceCheckStatus `json:"status"`
+	FlightID     string           `json:"flight_id"`
+	FareID       string           `json:"fare_id,omitempty"`
+	CachedPrice  Price            `json:"cached_price"`
+	CurrentPrice *Price           `json:"current_price,omitempty"`
+	CachedSeats  int16            `json:"cached_seats"`
+	CurrentSeats int16            `json:"current_seats"`
+	Flight       *Flight          `json:"flight,omitempty"`
+}
+
+// searchParamsOfFlight rebuild the provider query owning the flight from its cached data
+func searchParamsOfFlight(flight Flight) GetFlightsParams {
+	departureDate := flight.Departure.Datetime
+	if departureTime, err := time.Parse(time.RFC3339, flight.Departure.Datetime); err == nil {
+		departureDate = departureTime.Format("2006-01-02")
+	}
+	return GetFlightsParams{
+		Origin:        flight.Departure.Airport,
+		Destination:   flight.Arrival.Airport,
+		DepartureDate: departureDate,
+		CabinClass:    string(flight.CabinClass),
+		CabinOrBetter: true,
+	}
+}
+
+// ProviderOfFlightID read provider from flight ID, e.g. JT740_LionAir
+func ProviderOfFlightID(flightID string) string {
+	separator := strings.LastIndex(flightID, "_")
+	if separator < 0 {
+		return ""
+	}
+	return flightID[separator+1:]
+}
+
+// ScopedFlightID add the search route to the provider flight ID, e.g. JT740.1a2b3c4d5e6f_LionAir
+// the same flight searched on another date, cabin or passenger count get another ID so a lookup by ID return the searched flight
+func ScopedFlightID(flightID string, params GetFlightsParams) string {
+	separator := strings.LastIndex(flightID, "_")
+	if separator < 0 {
+		return flightID
+	}
+	return flightID[:separator] + "." + RouteScope(params.RouteKey()) + flightID[separator:]
+}
+
+// RouteScope is the route part of the scoped flight IDs, the first 12 hex of the route key SHA-256
+func RouteScope(routeKey string) string {
+	hash := sha256.Sum256([]byte(routeKey))
+	return hex.EncodeToString(hash[:6])
+}
+
+// ProviderFlightID remove the search route of a scoped flight ID, the provider only know its own ID
+func ProviderFlightID(flightID string) string {
+	separator := strings.LastIndex(flightID, "_")
+	if separator < 0 {
+		return flightID
+	}
+	scope := strings.LastIndex(flightID[:separator], ".")
+	if scope < 0 {
+		return flightID
+	}
+	return flightID[:scope] + flightID[separator:]
+}
+
+// withFlightID change the flight ID and the fare IDs built on it, e.g. GA400.1a2b3c4d5e6f_GarudaIndonesia_Y
+func withFlightID(flight Flight, flightID string) Flight {
+	fares := slices.Clone(flight.Fares)
+	for i, fare := range fares {
+		if suffix, found := strings.CutPrefix(fare.ID, flight.ID+"_"); found {
+			fares[i].ID = flightID + "_" + suffix
+		}
+	}
+	flight.ID = flightID
+	flight.Fares = fares
+	return flight
+}
+
+// scopeFlights scope the provider flight and fare IDs of a search before they are cached or returned
+func scopeFlights(flights []Flight, params GetFlightsParams) []Flight {
+	for i := range flights {
+		flights[i] = withFlightID(flights[i], ScopedFlightID(flights[i].ID, params))
+	}
+	return flights
+}
+
+func findFare(flight Flight, fareID string) (Fare, bool) {
+	for _, fare := range flight.Fares {
+		if fare.ID == fareID {
+			return fare, true
+		}
+	}
+	return Fare{}, false
+}
+
+func (s *InternalService) RevalidateFlightPrice(ctx context.Context, flightID string, params PriceCheckParams) (PriceCheckResult, error) {
+	if !allowedProvider(ctx, ProviderOfFlightID(flightID)) {
+		return PriceCheckResult{}, ErrFlightNotFound
+	}
+	cachedFlight, err := s.CacheService.GetFlightByID(ctx, flightID)
+	if err != nil {
+		return PriceCheckResult{}, err
+	}
+	if params.Passenger <= 0 {
+		params.Passenger = 1
+	}
+
+	// prices are compared after price rules, cache is only written with provider prices
+	pricedFlight := s.priceFlight(ctx, cachedFlight)
+	result := PriceCheckResult{
+		FlightID:    flightID,
+		FareID:      params.FareID,
+		CachedPrice: pricedFlight.Price,
+		CachedSeats: cachedFlight.AvailableSeats,
+	}
+	if params.FareID != "" {
+		cachedFare, exist := findFare(pricedFlight, params.FareID)
+		if !exist {
+			return PriceCheckResult{}, ErrFareNotFound
+		}
+		result.CachedPrice = cachedFare.Price
+		result.CachedSeats = cachedFare.AvailableSeats
+	}
+
+	currentFlight, err := s.FetcherService.GetFlight(cachedFlight.Provider, searchParamsOfFlight(cachedFlight), ProviderFlightID(flightID))
+	currentFlight = withFlightID(currentFlight, flightID)
+	if errors.Is(err, ErrFlightNotFound) {
+		result.Status = PriceCheckSoldOut
+		cachedFlight.AvailableSeats = 0
+		for i := range cachedFlight.Fares {
+			cachedFlight.Fares[i].AvailableSeats = 0
+		}
+		s.updateCachedFlight(ctx, cachedFlight)
+		return result, nil
+	}
+	if err != nil {
+		return PriceCheckResult{}, err
+	}
+
+	currentPriced := s.priceFlight(ctx, currentFlight)
+	currentPrice := currentPriced.Price
+	currentSeats := currentFlight.AvailableSeats
+	if params.FareID != "" {
+		currentFare, exist := findFare(currentPriced, params.FareID)
+		if !exist {
+			currentFare.AvailableSeats = 0
+		}
+		currentPrice = currentFare.Price
+		currentSeats = currentFare.AvailableSeats
+	}
+
+	result.CurrentSeats = currentSeats
+	result.Flight = &currentPriced
+	switch {
+	case currentSeats < params.Passenger:
+		result.Status = PriceCheckSoldOut
+	case currentPrice.AmountInIDR() != result.CachedPrice.AmountInIDR():
+		result.Status = PriceCheckChanged
+		result.CurrentPrice = &currentPrice
+	default:
+		result.Status = PriceCheckConfirmed
+		result.CurrentPrice = &currentPrice
+	}
+
+	s.updateCachedFlight(ctx, currentFlight)
+	return result, nil
+}
+
+func (s *InternalService) updateCachedFlight(ctx context.Context, flight Flight) {
+	if err := s.CacheService.UpdateFlight(ctx, flight); err != nil {
+		log.Printf("[%s]fail to update cached flight, Err : %v\n", flight.ID, err)
+	}
+}
@@ -0,0 +1,60 @@
+package internal
+
+import "testing"
+
+func TestScopedFlightID(t *testing.T) {
+	economy := GetFlightsParams{Origin: "CGK", Destination: "DPS", DepartureDate: "2025-12-15", CabinClass: "economy"}
+	nextDay := economy
+	nextDay.DepartureDate = "2025-12-16"
+	business := economy
+	business.CabinClass = "business"
+
+	scoped := ScopedFlightID("JT740_LionAir", economy)
+	tests := []struct {
+		name      string
+		flightID  string
+		params    GetFlightsParams
+		wantEqual bool
+	}{
+		{name: "same search", flightID: "JT740_LionAir", params: economy, wantEqual: true},
+		{name: "other date", flightID: "JT740_LionAir", params: nextDay},
+		{name: "other cabin", flightID: "JT740_LionAir", params: business},
+		{name: "other flight", flightID: "JT741_LionAir", params: economy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ScopedFlightID(tt.flightID, tt.params)
+			if (got == scoped) != tt.wantEqual {
+				t.Errorf("ScopedFlightID() = %s, compared to %s want equal %v", got, scoped, tt.wantEqual)
+			}
+			if provider := ProviderOfFlightID(got); provider != "LionAir" {
+				t.Errorf("ProviderOfFlightID(%s) = %s, want LionAir", got, provider)
+			}
+			if providerID := ProviderFlightID(got); providerID != tt.flightID {
+				t.Errorf("ProviderFlightID(%s) = %s, want %s", got, providerID, tt.flightID)
+			}
+		})
+	}
+
+	if got := ProviderFlightID("JT740_LionAir"); got != "JT740_LionAir" {
+		t.Errorf("ProviderFlightID() = %s, unscoped ID must be unchanged", got)
+	}
+}
+
+func TestScopeFlights(t *testing.T) {
+	params := GetFlightsParams{Origin: "CGK", Destination: "DPS", DepartureDate: "2025-12-15", CabinClass: "economy"}
+	flights := scopeFlights([]Flight{{
+		ID:    "GA400_GarudaIndonesia",
+		Fares: []Fare{{ID: "GA400_GarudaIndonesia_Y"}, {ID: "GA400_GarudaIndonesia_K"}},
+	}}, params)
+
+	flightID := ScopedFlightID("GA400_GarudaIndonesia", params)
+	if flights[0].ID != flightID {
+		t.Errorf("flight ID = %s, want %s", flights[0].ID, flightID)
+	}
+	for i, bookingClass := range []string{"Y", "K"} {
+		if want := flightID + "_" + bookingClass; flights[0].Fares[i].ID != want {
+			t.Errorf("fare ID = %s, want %s", flights[0].Fares[i].ID, want)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	PriceRuleSourceRedis = "redis"
+	PriceRuleSourceFile  = "file"
+	PriceRuleSourceNone  = "none"
+	priceRuleDateLayout  = "2006-01-02"
+)
+
+var (
+	ErrInvalidPriceRule   = errors.New("price rule is invalid")
+	ErrPriceRulesNotFound = errors.New("price rules are not found")
+)
+
+// PriceRule add a markup on top of the provider net price, empty match field match everything
+// amounts are in IDR and converted back to the price currency
+type PriceRule struct {
+	ID            string   `json:"id"`
+	TenantID      string   `json:"tenant_id"`
+	MarkupProfile string   `json:"markup_profile"` // tenant markup_profile
+	Airlines      []string `json:"airlines"`       // airline code, e.g. GarudaIndonesia
+	Origin        string   `json:"origin"`
+	Destination   string   `json:"destination"`
+	CabinClasses  []Class  `json:"cabin_classes"`
+	DepartureFrom string   `json:"departure_from"` // 2006-01-02, inclusive
+	DepartureTo   string   `json:"departure_to"`   // 2006-01-02, inclusive
+	MarkupPercent float64  `json:"markup_percent"` // negative is a discount
+	MarkupFixed   int      `json:"markup_fixed"`
+	MarkupFloor   *int     `json:"markup_floor"`   // minimum markup after percent and fixed
+	MarkupCeiling *int     `json:"markup_ceiling"` // maximum markup after percent and fixed
+	RoundTo       int      `json:"round_to"`       // selling price is rounded up to a multiple, e.g. 1000
+}
+
+func (r PriceRule) Validate() error {
+	if r.ID == "" {
+		return fmt.Errorf("price rule id must be filled : %w", ErrInvalidPriceRule)
+	}
+	if r.MarkupPercent <= -100 || r.RoundTo < 0 {
+		return fmt.Errorf("%s markup percent must be above -100 and round to must not be negative : %w", r.ID, ErrInvalidPriceRule)
+	}
+	if r.MarkupFloor != nil && r.MarkupCeiling != nil && *r.MarkupFloor > *r.MarkupCeiling {
+		return fmt.Errorf("%s markup floor is above ceiling : %w", r.ID, ErrInvalidPriceRule)
+	}
+	for _, cabin := range r.CabinClasses {
+		if !cabin.IsValid() {
+			return fmt.Errorf("%s cabin class %s is unknown : %w", r.ID, cabin, ErrInvalidPriceRule)
+		}
+	}
+	for _, date := range []string{r.DepartureFrom, r.DepartureTo} {
+		if _, err := time.Parse(priceRuleDateLayout, date); date != "" && err != nil {
+			return fmt.Errorf("%s departure date %s is invalid : %w", r.ID, date, ErrInvalidPriceRule)
+		}
+	}
+	if r.DepartureFrom != "" && r.DepartureTo != "" && r.DepartureFrom > r.DepartureTo {
+		return fmt.Errorf("%s departure from is after departure to : %w", r.ID, ErrInvalidPriceRule)
+	}
+	return nil
+}
+
+func ValidatePriceRules(rules []PriceRule) error {
+	ids := map[string]bool{}
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+		if ids[rule.ID] {
+			return fmt.Errorf("%s is duplicated : %w", rule.ID, ErrInvalidPriceRule)
+		}
+		ids[rule.ID] = true
+	}
+	return nil
+}
+
+// Match compare the rule with the tenant and flight, cabin is the fare cabin when pricing a fare
+func (r PriceRule) Match(tenant Tenant, flight Flight, cabin Class) bool {
+	if r.TenantID != "" && r.TenantID != tenant.ID {
+		return false
+	}
+	if r.MarkupProfile != "" && r.MarkupProfile != tenant.MarkupProfile {
+		return false
+	}
+	if len(r.Airlines) != 0 && !slices.Contains(r.Airlines, flight.Airline.Code) {
+		return false
+	}
+	if r.Origin != "" && r.Origin != flight.Departure.Airport {
+		return false
+	}
+	if r.Destination != "" && r.Destination != flight.Arrival.Airport {
+		return false
+	}
+	if len(r.CabinClasses) != 0 && !slices.Contains(r.CabinClasses, cabin) {
+		return false
+	}
+	departureDate := searchParamsOfFlight(flight).DepartureDate
+	if r.DepartureFrom != "" && departureDate < r.DepartureFrom {
+		return false
+	}
+	if r.DepartureTo != "" && departureDate > r.DepartureTo {
+		return false
+	}
+	return true
+}
+
+// Apply return the selling price of the net price
+func (r PriceRule) Apply(net Price) Price {
+	netIDR := net.AmountInIDR()
+	markup := int(math.Round(float64(netIDR)*r.MarkupPercent/100)) + r.MarkupFixed
+	if r.MarkupFloor != nil && markup < *r.MarkupFloor {
+		markup = *r.MarkupFloor
+	}
+	if r.MarkupCeiling != nil && markup > *r.MarkupCeiling {
+		markup = *r.MarkupCeiling
+	}
+	selling := max(netIDR+markup, 0)
+	if r.RoundTo > 0 {
+		selling = (selling + r.RoundTo - 1) / r.RoundTo * r.RoundTo
+	}
+	return Price{Amount: selling, Currency: "IDR"}.In(net.Currency)
+}
+
+type PriceRuleSet struct {
+	Source   string      `json:"source"` // redis, file or none
+	LoadedAt time.Time   `json:"loaded_at"`
+	Rules    []PriceRule `json:"rules"`
+}
+
+// priceRules hold the active rules, replaced as a whole on reload
+type priceRules struct {
+	mu          sync.RWMutex
+	set         PriceRuleSet
+	fileModTime time.Time
+}
+
+func newPriceRules() *priceRules {
+	return &priceRules{set: PriceRuleSet{Source: PriceRuleSourceNone, Rules: []PriceRule{}}}
+}
+
+func (p *priceRules) get() PriceRuleSet {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.set
+}
+
+// replace the active rules, fileModTime is only set for rules of PRICE_RULES_FILE
+func (p *priceRules) replace(source string, rules []PriceRule, fileModTime time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.set = PriceRuleSet{Source: source, LoadedAt: time.Now(), Rules: rules}
+	p.fileModTime = fileModTime
+}
+
+func (p *priceRules) isLoaded(source string, fileModTime time.Time) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.set.Source == source && p.fileModTime.Equal(fileModTime)
+}
+
+// match return the first matching rule, rules are ordered by priority
+func (p *priceRules) match(tenant Tenant, flight Flight, cabin Class) (PriceRule, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, rule := range p.set.Rules {
+		if rule.Match(tenant, flight, cabin) {
+			return rule, true
+		}
+	}
+	return PriceRule{}, false
+}
+
+// priceFlight set the selling price of the flight and its fares, the provider price is kept in NetPrice
+// fares are copied so cached flights are untouched
+func (s *InternalService) priceFlight(ctx context.Context, flight Flight) Flight {
+	tenant, _ := TenantFromContext(ctx)
+	net := flight.Price
+	flight.NetPrice = &net
+	flight.PriceRuleID = ""
+	if rule, exist := s.priceRules.match(tenant, flight, flight.CabinClass); exist {
+		flight.Price = rule.Apply(net)
+		flight.PriceRuleID = rule.ID
+	}
+
+	fares := make([]Fare, len(flight.Fares))
+	for i, fare := range flight.Fares {
+		fareNet := fare.Price
+		fare.NetPrice = &fareNet
+		if rule, exist := s.priceRules.match(tenant, flight, fare.CabinClass); exist {
+			fare.Price = rule.Apply(fareNet)
+		}
+		fares[i] = fare
+	}
+	flight.Fares = fares
+	return flight
+}
+
+func (s *InternalService) priceFlights(ctx context.Context, flights []Flight) []Flight {
+	for i := range flights {
+		flights[i] = s.priceFlight(ctx, flights[i])
+	}
+	return flights
+}
+
+// ReloadPriceRules use the rules saved in Redis, otherwise PRICE_RULES_FILE when it changed
+// invalid or unavailable rules keep the active rules
+func (s *InternalService) ReloadPriceRules(ctx context.Context) {
+	rules, err := s.CacheService.GetPriceRules(ctx)
+	if err == nil {
+		if err := ValidatePriceRules(rules); err != nil {
+			log.Printf("fail to reload price rules from redis, Err : %v\n", err)
+			return
+		}
+		s.priceRules.replace(PriceRuleSourceRedis, rules, time.Time{})
+		return
+	}
+	if !errors.Is(err, ErrPriceRulesNotFound) {
+		log.Printf("fail to get price rules, Err : %v\n", err)
+		return
+	}
+	if s.Cfg.PriceRulesFile == "" {
+		if !s.priceRules.isLoaded(PriceRuleSourceNone, time.Time{}) {
+			s.priceRules.replace(PriceRuleSourceNone, []PriceRule{}, time.Time{})
+		}
+		return
+	}
+	s.reloadPriceRulesFile()
+}
+
+func (s *InternalService) reloadPriceRulesFile() {
+	info, err := os.Stat(s.Cfg.PriceRulesFile)
+	if err != nil {
+		log.Printf("fail to stat price rules file, Err : %v\n", err)
+		return
+	}
+	if s.priceRules.isLoaded(PriceRuleSourceFile, info.ModTime()) {
+		return
+	}
+
+	content, err := os.ReadFile(s.Cfg.PriceRulesFile)
+	if err != nil {
+		log.Printf("fail to read price rules file, Err : %v\n", err)
+		return
+	}
+	rules := []PriceRule{}
+	if err := json.Unmarshal(content, &rules); err != nil {
+		log.Printf("fail to parse price rules file, Err : %v\n", err)
+		return
+	}
+	if err := ValidatePriceRules(rules); err != nil {
+		log.Printf("fail to reload price rules from file, Err : %v\n", err)
+		return
+	}
+	s.priceRules.replace(PriceRuleSourceFile, rules, info.ModTime())
+}
+
+// StartPriceRulesReload reload the rules every PRICE_RULES_RELOAD_INTERVAL until ctx is done
+func (s *InternalService) StartPriceRulesReload(ctx context.Context) {
+	ticker := time.NewTicker(s.Cfg.PriceRulesReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.ReloadPriceRules(ctx)
+		}
+	}
+}
+
+func (s *InternalService) GetPriceRules() PriceRuleSet {
+	return s.priceRules.get()
+}
+
+// SetPriceRules save the rules in Redis, other instances pick them up on their next reload
+func (s *InternalService) SetPriceRules(ctx context.Context, rules []PriceRule) (PriceRuleSet, error) {
+	if err := s.CacheService.SetPriceRules(ctx, rules); err != nil {
+		return PriceRuleSet{}, err
+	}
+	s.priceRules.replace(PriceRuleSourceRedis, rules, time.Time{})
+	return s.priceRules.get(), nil
+}
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+// readmeRules is the example of the README Price Rules section
+var readmeRules = []PriceRule{
+	{
+		ID:            "acme-ga-business",
+		TenantID:      "acme",
+		Airlines:      []string{"GarudaIndonesia"},
+		Origin:        "CGK",
+		Destination:   "DPS",
+		CabinClasses:  []Class{"business"},
+		DepartureFrom: "2025-12-20",
+		DepartureTo:   "2026-01-05",
+		MarkupPercent: 8,
+		MarkupFixed:   25000,
+		MarkupFloor:   intPtr(50000),
+		MarkupCeiling: intPtr(300000),
+		RoundTo:       1000,
+	},
+	{ID: "default", MarkupPercent: 3},
+}
+
+// pricingFlight is built like the provider normalizers, airline code is the provider name and name the IATA code
+func pricingFlight(airline, origin, departure string, cabin Class, amount int) Flight {
+	return Flight{
+		ID:         "GA400_GarudaIndonesia",
+		Provider:   "GarudaIndonesia",
+		Airline:    Airline{Code: airline, Name: "GA"},
+		Departure:  Airport{Airport: origin, Datetime: departure},
+		Arrival:    Airport{Airport: "DPS"},
+		CabinClass: cabin,
+		Price:      Price{Amount: amount, Currency: "IDR"},
+	}
+}
+
+func TestPriceRuleApply(t *testing.T) {
+	rule := readmeRules[0]
+	tests := []struct {
+		name string
+		net  Price
+		want Price
+	}{
+		{name: "percent and fixed", net: Price{Amount: 1000000, Currency: "IDR"}, want: Price{Amount: 1105000, Currency: "IDR"}},
+		{name: "floor", net: Price{Amount: 100000, Currency: "IDR"}, want: Price{Amount: 150000, Currency: "IDR"}},
+		{name: "ceiling", net: Price{Amount: 5000000, Currency: "IDR"}, want: Price{Amount: 5300000, Currency: "IDR"}},
+		{name: "round up", net: Price{Amount: 1234567, Currency: "IDR"}, want: Price{Amount: 1359000, Currency: "IDR"}},
+		{name: "usd converted back", net: Price{Amount: 100, Currency: "USD"}, want: Price{Amount: 110, Currency: "USD"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rule.Apply(tt.net); got != tt.want {
+				t.Errorf("Apply() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	discount := PriceRule{ID: "discount", MarkupFixed: -200000}
+	if got := discount.Apply(Price{Amount: 150000, Currency: "IDR"}); got.Amount != 0 {
+		t.Errorf("Apply() = %d, selling price must not be negative", got.Amount)
+	}
+}
+
+func TestPriceRulesMatch(t *testing.T) {
+	acme := Tenant{ID: "acme"}
+	rules := newPriceRules()
+	rules.replace(PriceRuleSourceFile, readmeRules, time.Time{})
+	tests := []struct {
+		name     string
+		tenant   Tenant
+		flight   Flight
+		cabin    Class
+		wantRule string
+	}{
+		{name: "readme example", tenant: acme, flight: pricingFlight("GarudaIndonesia", "CGK", "2025-12-24T08:00:00+07:00", "business", 1000000), cabin: "business", wantRule: "acme-ga-business"},
+		{name: "last departure date is inclusive", tenant: acme, flight: pricingFlight("GarudaIndonesia", "CGK", "2026-01-05T23:00:00+07:00", "business", 1000000), cabin: "business", wantRule: "acme-ga-business"},
+		{name: "other airline", tenant: acme, flight: pricingFlight("LionAir", "CGK", "2025-12-24T08:00:00+07:00", "business", 1000000), cabin: "business", wantRule: "default"},
+		{name: "other tenant", tenant: Tenant{ID: "globex"}, flight: pricingFlight("GarudaIndonesia", "CGK", "2025-12-24T08:00:00+07:00", "business", 1000000), cabin: "business", wantRule: "default"},
+		{name: "other origin", tenant: acme, flight: pricingFlight("GarudaIndonesia", "SUB", "2025-12-24T08:00:00+07:00", "business", 1000000), cabin: "business", wantRule: "default"},
+		{name: "fare cabin", tenant: acme, flight: pricingFlight("GarudaIndonesia", "CGK", "2025-12-24T08:00:00+07:00", "business", 1000000), cabin: "economy", wantRule: "default"},
+		{name: "before departure from", tenant: acme, flight: pricingFlight("GarudaIndonesia", "CGK", "2025-12-19T08:00:00+07:00", "business", 1000000), cabin: "business", wantRule: "default"},
+		{name: "after departure to", tenant: acme, flight: pricingFlight("GarudaIndonesia", "CGK", "2026-01-06T08:00:00+07:00", "business", 1000000), cabin: "business", wantRule: "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, exist := rules.match(tt.tenant, tt.flight, tt.cabin)
+			if !exist || rule.ID != tt.wantRule {
+				t.Errorf("match() = %s, want %s", rule.ID, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestPriceFlightReadmeExample(t *testing.T) {
+	s := &InternalService{priceRules: newPriceRules()}
+	s.priceRules.replace(PriceRuleSourceFile, readmeRules, time.Time{})
+	flight := pricingFlight("GarudaIndonesia", "CGK", "2025-12-24T08:00:00+07:00", "business", 1000000)
+	flight.Fares = []Fare{
+		{ID: "GA400_GarudaIndonesia_C", CabinClass: "business", Price: Price{Amount: 1000000, Currency: "IDR"}},
+		{ID: "GA400_GarudaIndonesia_Y", CabinClass: "economy", Price: Price{Amount: 500000, Currency: "IDR"}},
+	}
+
+	priced := s.priceFlight(WithTenant(t.Context(), Tenant{ID: "acme"}), flight)
+	if priced.Price.Amount != 1105000 || priced.NetPrice.Amount != 1000000 || priced.PriceRuleID != "acme-ga-business" {
+		t.Errorf("flight price = %d net %d rule %s, want 1105000 net 1000000 rule acme-ga-business", priced.Price.Amount, priced.NetPrice.Amount, priced.PriceRuleID)
+	}
+	if priced.Fares[0].Price.Amount != 1105000 {
+		t.Errorf("business fare price = %d, want 1105000", priced.Fares[0].Price.Amount)
+	}
+	if priced.Fares[1].Price.Amount != 515000 {
+		t.Errorf("economy fare price = %d, want 515000 from the default rule", priced.Fares[1].Price.Amount)
+	}
+	if flight.Fares[0].NetPrice != nil {
+		t.Errorf("cached fares must not be changed")
+	}
+}
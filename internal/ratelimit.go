@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"kevinjuniawan/bookcabin/config"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultRateLimitTier = "default"
+
+var ErrInvalidRateLimitTier = errors.New("rate limit tier is invalid")
+
+// RateLimitTier allow Limit requests per client in any sliding Window, Limit 0 is unlimited
+type RateLimitTier struct {
+	Name   string
+	Limit  int64
+	Window time.Duration
+}
+
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int64
+	Remaining  int64
+	RetryAfter time.Duration // until the oldest request leave the window, zero when allowed
+}
+
+// ParseRateLimitTier parse NAME:LIMIT/WINDOW, e.g. partner:600/1m
+func ParseRateLimitTier(tier string) (RateLimitTier, error) {
+	name, rule, found := strings.Cut(strings.TrimSpace(tier), ":")
+	limit, window, hasWindow := strings.Cut(rule, "/")
+	if !found || name == "" || !hasWindow {
+		return RateLimitTier{}, fmt.Errorf("%s : %w", tier, ErrInvalidRateLimitTier)
+	}
+	max, err := strconv.ParseInt(limit, 10, 64)
+	if err != nil || max < 0 {
+		return RateLimitTier{}, fmt.Errorf("%s : %w", tier, ErrInvalidRateLimitTier)
+	}
+	duration, err := time.ParseDuration(window)
+	if err != nil || duration <= 0 {
+		return RateLimitTier{}, fmt.Errorf("%s : %w", tier, ErrInvalidRateLimitTier)
+	}
+	return RateLimitTier{Name: name, Limit: max, Window: duration}, nil
+}
+
+// NewRateLimitTiers load RATE_LIMIT_TIERS, the default tier come from REQUEST_LIMITER_MAX and REQUEST_LIMITER_TTL unless it is overridden
+func NewRateLimitTiers(cfg config.Config) map[string]RateLimitTier {
+	tiers := map[string]RateLimitTier{
+		DefaultRateLimitTier: {Name: DefaultRateLimitTier, Limit: cfg.RequestLimiterMax, Window: cfg.RequestLimiterTTL},
+	}
+	for _, tier := range strings.Split(cfg.RateLimitTiers, ",") {
+		if strings.TrimSpace(tier) == "" {
+			continue
+		}
+		parsed, err := ParseRateLimitTier(tier)
+		if err != nil {
+			log.Printf("fail to parse rate limit tier config, Err : %v\n", err)
+			continue
+		}
+		tiers[parsed.Name] = parsed
+	}
+	return tiers
+}
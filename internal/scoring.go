@@ -0,0 +1,230 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"kevinjuniawan/bookcabin/config"
+	"log"
+	"math"
+	"time"
+)
+
+var ErrUnknownScoringProfile = errors.New("scoring profile is unknown")
+
+const (
+	ScoringProfileBalanced          = "balanced"
+	ScoringProfileBudget            = "budget"
+	ScoringProfileComfort           = "comfort"
+	ScoringProfileBusinessTraveller = "business_traveller"
+)
+
+// Every factor is normalized into a comparable unit before weighted, score is lower is better
+const (
+	scorePriceUnit    = 1000000 // IDR
+	scoreDurationUnit = 60      // minute
+	scoreLayoverUnit  = 60      // minute
+)
+
+var comfortAmenities = []Amenity{AmenityWifi, AmenityMeal, AmenityEntertainment, AmenityPowerOutlet}
+
+type ScoreFactors struct {
+	Price         float64 `json:"price"`
+	Duration      float64 `json:"duration"`
+	Stops         float64 `json:"stops"`
+	Layover       float64 `json:"layover"`
+	Baggage       float64 `json:"baggage"`
+	Amenities     float64 `json:"amenities"`
+	DepartureTime float64 `json:"departure_time"`
+}
+
+func (f ScoreFactors) IsValid() bool {
+	return f.Price >= 0 && f.Duration >= 0 && f.Stops >= 0 && f.Layover >= 0 && f.Baggage >= 0 && f.Amenities >= 0 && f.DepartureTime >= 0
+}
+
+type DepartureWindow struct {
+	FromHour int `json:"from_hour" validate:"min=0,max=23"`
+	ToHour   int `json:"to_hour" validate:"min=0,max=23"`
+}
+
+func (w DepartureWindow) IsValid() bool {
+	return w.FromHour >= 0 && w.FromHour <= 23 && w.ToHour >= 0 && w.ToHour <= 23 && w.FromHour <= w.ToHour
+}
+
+type ScoringProfile struct {
+	Name               string           `json:"name"`
+	Weights            ScoreFactors     `json:"weights"`
+	PreferredDeparture *DepartureWindow `json:"preferred_departure,omitempty"`
+}
+
+type ScoringParams struct {
+	Profile            string           `json:"profile" validate:"omitempty"`
+	Weights            *ScoreFactors    `json:"weights" validate:"omitempty"`
+	PreferredDeparture *DepartureWindow `json:"preferred_departure" validate:"omitempty"`
+}
+
+type ScoreBreakdown struct {
+	Profile    string       `json:"profile"`
+	Total      float64      `json:"total"`
+	Components ScoreFactors `json:"components"`
+}
+
+var defaultScoringProfiles = map[string]ScoringProfile{
+	ScoringProfileBalanced: {
+		Name:    ScoringProfileBalanced,
+		Weights: ScoreFactors{Price: 1, Duration: 0.6, Stops: 0.3, Layover: 0.2, Baggage: 0.2, Amenities: 0.1},
+	},
+	ScoringProfileBudget: {
+		Name:    ScoringProfileBudget,
+		Weights: ScoreFactors{Price: 1, Duration: 0.2, Stops: 0.1, Layover: 0.05, Baggage: 0.3},
+	},
+	ScoringProfileComfort: {
+		Name:    ScoringProfileComfort,
+		Weights: ScoreFactors{Price: 0.5, Duration: 0.6, Stops: 0.6, Layover: 0.4, Baggage: 0.3, Amenities: 0.4},
+	},
+	ScoringProfileBusinessTraveller: {
+		Name:               ScoringProfileBusinessTraveller,
+		Weights:            ScoreFactors{Price: 0.2, Duration: 1, Stops: 0.8, Layover: 0.6, Baggage: 0.1, Amenities: 0.3, DepartureTime: 0.3},
+		PreferredDeparture: &DepartureWindow{FromHour: 6, ToHour: 10},
+	},
+}
+
+type Scorer struct {
+	Profiles       map[string]ScoringProfile
+	DefaultProfile string
+}
+
+// NewScorer load built-in profiles, then profiles from SCORING_PROFILES override or extend them
+func NewScorer(cfg config.Config) *Scorer {
+	profiles := make(map[string]ScoringProfile, len(defaultScoringProfiles))
+	for name, profile := range defaultScoringProfiles {
+		profiles[name] = profile
+	}
+
+	if cfg.ScoringProfiles != "" {
+		configProfiles := map[string]ScoringProfile{}
+		if err := json.Unmarshal([]byte(cfg.ScoringProfiles), &configProfiles); err != nil {
+			log.Printf("fail to parse scoring profiles config, Err : %v\n", err)
+		}
+		for name, profile := range configProfiles {
+			if !profile.Weights.IsValid() || (profile.PreferredDeparture != nil && !profile.PreferredDeparture.IsValid()) {
+				log.Printf("[%s] invalid scoring profile config\n", name)
+				continue
+			}
+			profile.Name = name
+			profiles[name] = profile
+		}
+	}
+
+	defaultProfile := cfg.DefaultScoringProfile
+	if _, exist := profiles[defaultProfile]; !exist {
+		defaultProfile = ScoringProfileBalanced
+	}
+
+	return &Scorer{
+		Profiles:       profiles,
+		DefaultProfile: defaultProfile,
+	}
+}
+
+func (s *Scorer) ResolveProfile(params *ScoringParams) (ScoringProfile, error) {
+	profile := s.Profiles[s.DefaultProfile]
+	if params == nil {
+		return profile, nil
+	}
+
+	if params.Profile != "" {
+		configProfile, exist := s.Profiles[params.Profile]
+		if !exist {
+			return ScoringProfile{}, ErrUnknownScoringProfile
+		}
+		profile = configProfile
+	}
+	if params.Weights != nil {
+		profile.Name = "custom"
+		profile.Weights = *params.Weights
+	}
+	if params.PreferredDeparture != nil {
+		profile.PreferredDeparture = params.PreferredDeparture
+	}
+	return profile, nil
+}
+
+func (s *Scorer) Score(flight Flight, profile ScoringProfile) ScoreBreakdown {
+	weights := profile.Weights
+	components := ScoreFactors{
+		Price:         weights.Price * float64(flight.Price.AmountInIDR()) / scorePriceUnit,
+		Duration:      weights.Duration * float64(flight.Duration.TotalMinute) / scoreDurationUnit,
+		Stops:         weights.Stops * float64(flight.Stops),
+		Layover:       weights.Layover * float64(flight.Layover) / scoreLayoverUnit,
+		Baggage:       weights.Baggage * baggagePenalty(flight.Baggage),
+		Amenities:     weights.Amenities * amenitiesPenalty(flight.Amenities),
+		DepartureTime: weights.DepartureTime * departurePenalty(flight.Departure, profile.PreferredDeparture),
+	}
+	components = ScoreFactors{
+		Price:         roundScore(components.Price),
+		Duration:      roundScore(components.Duration),
+		Stops:         roundScore(components.Stops),
+		Layover:       roundScore(components.Layover),
+		Baggage:       roundScore(components.Baggage),
+		Amenities:     roundScore(components.Amenities),
+		DepartureTime: roundScore(components.DepartureTime),
+	}
+
+	return ScoreBreakdown{
+		Profile: profile.Name,
+		Total: roundScore(components.Price + components.Duration + components.Stops + components.Layover +
+			components.Baggage + components.Amenities + components.DepartureTime),
+		Components: components,
+	}
+}
+
+func (s *Scorer) ScoreWithDefaultProfile(flight Flight) ScoreBreakdown {
+	return s.Score(flight, s.Profiles[s.DefaultProfile])
+}
+
+func baggagePenalty(baggage Bag) float64 {
+	if baggage.Checked.IsIncluded() {
+		return 0
+	}
+	return 1
+}
+
+func amenitiesPenalty(amenities []Amenity) float64 {
+	missing := 0
+	for _, amenity := range comfortAmenities {
+		found := false
+		for _, flightAmenity := range amenities {
+			if flightAmenity == amenity {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing++
+		}
+	}
+	return float64(missing) / float64(len(comfortAmenities))
+}
+
+// departurePenalty is the distance in hour between local departure time and the preferred window
+func departurePenalty(departure Airport, window *DepartureWindow) float64 {
+	if window == nil {
+		return 0
+	}
+	departureTime, err := time.Parse(time.RFC3339, departure.Datetime)
+	if err != nil {
+		return 0
+	}
+	hour := float64(departureTime.Hour()) + float64(departureTime.Minute())/60
+	if hour < float64(window.FromHour) {
+		return float64(window.FromHour) - hour
+	}
+	if hour > float64(window.ToHour)+1 {
+		return hour - float64(window.ToHour) - 1
+	}
+	return 0
+}
+
+func roundScore(score float64) float64 {
+	return math.Round(score*10000) / 10000
+}
@@ -1,30 +1,55 @@
 package internal
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"kevinjuniawan/bookcabin/config"
 	"kevinjuniawan/bookcabin/pkg/helper"
+	"log"
+	"slices"
 	"sort"
 	"time"
-
-	"github.com/go-redis/redis/v8"
 )
 
 type InternalService struct {
-	FetcherService IFetcher
-	CacheService   ICache
-	Cfg            config.Config
+	FetcherService  IFetcher
+	CacheService    ICache
+	BookingProvider IBookingProvider
+	Analytics       IAnalytics
+	Scorer          *Scorer
+	Cfg             config.Config
+	inflight        *fetchGroup
+	cacheBreaker    *cacheBreaker
+	tenants         map[string]Tenant // from TENANTS config
+	priceRules      *priceRules
+	tenantKeys      map[string]string // API key hash to tenant ID of TENANTS config
+	resolvedKeys    *resolvedKeys
 }
 
 type InternalServiceParams struct {
-	FetcherService IFetcher
-	CacheService   ICache
+	FetcherService  IFetcher
+	CacheService    ICache
+	BookingProvider IBookingProvider
+	Analytics       IAnalytics
+	Cfg             config.Config
 }
 
 func NewInternalService(params InternalServiceParams) *InternalService {
+	tenants, tenantKeys := NewTenants(params.Cfg)
 	return &InternalService{
-		FetcherService: params.FetcherService,
-		CacheService:   params.CacheService,
+		FetcherService:  params.FetcherService,
+		CacheService:    params.CacheService,
+		BookingProvider: params.BookingProvider,
+		Analytics:       params.Analytics,
+		Scorer:          NewScorer(params.Cfg),
+		Cfg:             params.Cfg,
+		inflight:        newFetchGroup(),
+		cacheBreaker:    newCacheBreaker(params.Cfg.CacheBypassTime),
+		tenants:         tenants,
+		priceRules:      newPriceRules(),
+		tenantKeys:      tenantKeys,
+		resolvedKeys:    newResolvedKeys(params.Cfg.APIKeyCacheTTL),
 	}
 }
 
@@ -33,29 +58,82 @@ func (s *InternalService) GetFlights(ctx context.Context, params GetFlightsParam
 	providerCount := int16(0)
 	succeededProvider := int16(0)
 	isCache := true
-	flightsList, err := s.CacheService.GetSortedFlightsByParams(ctx, params)
+	isCoalesced := false
+	isCacheBypassed := s.cacheBreaker.isOpen()
+	scoringProfile, err := s.Scorer.ResolveProfile(params.Scoring)
 	if err != nil {
-		if err != redis.Nil {
-			return SearchResponse{Metadata: Metadata{IsCache: isCache}}, err
+		return SearchResponse{}, err
+	}
+
+	var travellerProfile *TravellerProfile
+	if params.ProfileID != "" && !isCacheBypassed {
+		profile, err := s.CacheService.GetTravellerProfile(ctx, tenantID(ctx), params.ProfileID)
+		if err != nil && !errors.Is(err, ErrTravellerProfileNotFound) {
+			s.cacheFailed(params.ProfileID, err)
+			isCacheBypassed = true // search is answered without personalization
+		} else if err != nil {
+			return SearchResponse{}, err
+		} else {
+			travellerProfile = &profile
+		}
+	}
+
+	flightsList := []Flight{}
+	err = ErrCacheMiss
+	if !isCacheBypassed {
+		flightsList, err = s.CacheService.GetSortedFlightsByParams(ctx, params)
+	}
+	if err != nil {
+		if !errors.Is(err, ErrCacheMiss) {
+			s.cacheFailed(params.RouteKey(), err)
+			isCacheBypassed = true
 		}
 		isCache = false
-		flightsData, err := s.FetcherService.GetFlights(params)
+		// Only the first of concurrent identical searches fetch the providers and write the cache
+		flightsData, coalesced, err := s.inflight.do(params.RouteKey(), func() (FlightDataResponse, error) {
+			flightsData, err := s.FetcherService.GetFlights(params)
+			if err != nil {
+				s.releaseRebuildLock(params)
+				return flightsData, err
+			}
+			flightsData.Flights = scopeFlights(flightsData.Flights, params)
+			if isCacheBypassed {
+				s.releaseRebuildLock(params)
+				return flightsData, nil
+			}
+			cachedFlights := copyFlightData(flightsData).Flights
+			go func() {
+				ctxSet := context.Background()
+				if err := s.CacheService.SetFlights(ctxSet, cachedFlights, params, time.Duration(0)); err != nil {
+					s.cacheFailed(params.RouteKey(), err)
+				}
+			}()
+			return flightsData, nil
+		})
+		isCoalesced = coalesced
 		if err != nil {
-			return SearchResponse{Metadata: Metadata{IsCache: isCache}}, err
+			return SearchResponse{Metadata: Metadata{IsCache: isCache, IsCoalesced: isCoalesced, IsCacheBypassed: isCacheBypassed}}, err
 		}
 		flightsList = flightsData.Flights
-		go func() {
-			ctxSet := context.Background()
-			s.CacheService.SetFlights(ctxSet, flightsList, params, time.Duration(0))
-		}()
-		flightsList = s.sortFlight(flightsList, params.SortType)
 		providerCount = flightsData.ProviderCount
 		succeededProvider = flightsData.ProviderCount - flightsData.FailedProvider
 	}
 
+	// Cache is shared by tenants, flights of providers the tenant can not sell are dropped after
+	flightsList = tenantFlights(ctx, flightsList)
+	flightsList = s.priceFlights(ctx, flightsList)
+
+	// Cache and provider result is sorted in the same way so both return identical ordering
+	flightsList = s.scoreFlight(flightsList, scoringProfile)
+	flightsList = s.sortFlight(flightsList, params.SortKeys())
+	if travellerProfile != nil {
+		flightsList = rankByProfile(flightsList, *travellerProfile)
+	}
+
 	if params.Filter != nil {
 		flightsList = FilterFlight(flightsList, *params.Filter)
 	}
+	flightsList = tenantCurrency(ctx, flightsList)
 
 	duration := time.Since(startSearch)
 
@@ -65,11 +143,20 @@ func (s *InternalService) GetFlights(ctx context.Context, params GetFlightsParam
 			SucceededProvider: succeededProvider,
 			SearchTimeMs:      int32(duration.Milliseconds()),
 			IsCache:           isCache,
+			IsCoalesced:       isCoalesced,
+			IsCacheBypassed:   isCacheBypassed,
 		},
 		Flights: flightsList,
 	}, nil
 }
 
+// releaseRebuildLock let another replica rebuild the route when this search does not write the cache
+func (s *InternalService) releaseRebuildLock(params GetFlightsParams) {
+	if err := s.CacheService.ReleaseRebuildLock(context.Background(), params); err != nil {
+		log.Printf("[%s]fail to release rebuild lock, Err : %v\n", params.RouteKey(), err)
+	}
+}
+
 func FilterFlight(flights []Flight, params FilterFlightParams) []Flight {
 	filteredFlights := []Flight{}
 	for _, flight := range flights {
@@ -80,7 +167,7 @@ func FilterFlight(flights []Flight, params FilterFlightParams) []Flight {
 		}
 
 		if params.Price != nil {
-			if flight.Price.AmountInIDR() >= params.Price.LowestPrice && flight.Price.AmountInIDR() <= params.Price.HighestPrice {
+			if flight.Price.AmountInIDR() < params.Price.LowestPrice || flight.Price.AmountInIDR() > params.Price.HighestPrice {
 				continue
 			}
 		}
@@ -91,15 +178,55 @@ func FilterFlight(flights []Flight, params FilterFlightParams) []Flight {
 			}
 		}
 
+		if params.MaxDuration != nil && flight.Duration.TotalMinute > *params.MaxDuration {
+			continue
+		}
+
+		if params.MaxStops != nil && flight.Stops > *params.MaxStops {
+			continue
+		}
+
+		if params.Layover != nil && !matchLayover(flight.Layovers, *params.Layover) {
+			continue
+		}
+
+		if params.ConnectionAirports != nil && !matchConnectionAirports(flight.Layovers, *params.ConnectionAirports) {
+			continue
+		}
+
+		if len(params.FareFamilies) != 0 && !flight.HasFareFamily(params.FareFamilies) {
+			continue
+		}
+
+		if params.CheckedBaggageIncluded && !flight.Baggage.Checked.IsIncluded() {
+			continue
+		}
+
+		if len(params.Amenities) != 0 && !flight.HasAmenities(params.Amenities) {
+			continue
+		}
+
+		if len(params.Aircraft) != 0 || params.AircraftBody != "" {
+			if flight.Aircraft == nil {
+				continue
+			}
+			if params.AircraftBody != "" && flight.Aircraft.Body != params.AircraftBody {
+				continue
+			}
+			if len(params.Aircraft) != 0 && !matchAircraft(*flight.Aircraft, params.Aircraft) {
+				continue
+			}
+		}
+
 		if params.TimeRange != nil {
 			fromTime, _ := time.Parse(time.RFC3339, params.TimeRange.From)
 			toTime, _ := time.Parse(time.RFC3339, params.TimeRange.To)
 			if params.TimeRange.Type == FilterFlightTimeTypeDeparture {
-				if flight.Departure.Timestamp >= fromTime.Unix() && flight.Departure.Timestamp <= toTime.Unix() {
+				if flight.Departure.Timestamp < fromTime.Unix() || flight.Departure.Timestamp > toTime.Unix() {
 					continue
 				}
 			} else if params.TimeRange.Type == FilterFlightTimeTypeArrival {
-				if flight.Arrival.Timestamp >= fromTime.Unix() && flight.Arrival.Timestamp <= toTime.Unix() {
+				if flight.Arrival.Timestamp < fromTime.Unix() || flight.Arrival.Timestamp > toTime.Unix() {
 					continue
 				}
 			}
@@ -109,44 +236,168 @@ func FilterFlight(flights []Flight, params FilterFlightParams) []Flight {
 	return filteredFlights
 }
 
-func (s *InternalService) sortFlight(flights []Flight, sortType SortType) []Flight {
-	switch sortType {
-	case SortLowestPriceType:
-		sort.Slice(flights, func(i, j int) bool {
-			return flights[i].Price.AmountInIDR() < flights[j].Price.AmountInIDR()
-		})
-	case SortHighestPriceType:
-		sort.Slice(flights, func(i, j int) bool {
-			return flights[i].Price.AmountInIDR() > flights[j].Price.AmountInIDR()
-		})
-	case SortShortestDurationType:
-		sort.Slice(flights, func(i, j int) bool {
-			return flights[i].Duration.TotalMinute < flights[j].Duration.TotalMinute
-		})
-	case SortLongestDurationType:
-		sort.Slice(flights, func(i, j int) bool {
-			return flights[i].Duration.TotalMinute > flights[j].Duration.TotalMinute
-		})
-	case SortDepartureType:
-		sort.Slice(flights, func(i, j int) bool {
-			return flights[i].Departure.Timestamp < flights[j].Departure.Timestamp
-		})
-	case SortArrivalType:
-		sort.Slice(flights, func(i, j int) bool {
-			return flights[i].Arrival.Timestamp < flights[j].Arrival.Timestamp
-		})
-	case SortBestValueType:
-		sort.Slice(flights, func(i, j int) bool {
-			return CalculateBestValue(flights[i]) < CalculateBestValue(flights[j])
-		})
+// FilterFlightByCabin keep only fares in the requested cabin, flight main fare is replaced by the cheapest accepted fare when needed
+func FilterFlightByCabin(flights []Flight, cabin Class, orBetter bool) []Flight {
+	filteredFlights := []Flight{}
+	for _, flight := range flights {
+		if len(flight.Fares) == 0 {
+			if flight.CabinClass.Accept(cabin, orBetter) {
+				filteredFlights = append(filteredFlights, flight)
+			}
+			continue
+		}
+
+		fares := []Fare{}
+		for _, fare := range flight.Fares {
+			if fare.CabinClass.Accept(cabin, orBetter) {
+				fares = append(fares, fare)
+			}
+		}
+		if len(fares) == 0 {
+			continue
+		}
+
+		flight.Fares = fares
+		if !flight.CabinClass.Accept(cabin, orBetter) {
+			cheapest := slices.MinFunc(fares, func(a, b Fare) int {
+				return cmp.Compare(a.Price.AmountInIDR(), b.Price.AmountInIDR())
+			})
+			flight.Price = cheapest.Price
+			flight.CabinClass = cheapest.CabinClass
+			flight.AvailableSeats = cheapest.AvailableSeats
+		}
+		filteredFlights = append(filteredFlights, flight)
+	}
+	return filteredFlights
+}
+
+func matchLayover(layovers []Layover, params FilterFlightLayoverParams) bool {
+	for _, layover := range layovers {
+		if params.MinMinute != nil && layover.TotalMinute < *params.MinMinute {
+			return false
+		}
+		if params.MaxMinute != nil && layover.TotalMinute > *params.MaxMinute {
+			return false
+		}
+	}
+	return true
+}
+
+func matchConnectionAirports(layovers []Layover, params FilterFlightConnectionParams) bool {
+	for _, layover := range layovers {
+		if len(params.Include) != 0 && !helper.ExistInSliceString(params.Include, layover.Airport) {
+			return false
+		}
+		if helper.ExistInSliceString(params.Exclude, layover.Airport) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchAircraft(aircraft Aircraft, aircraftTypes []string) bool {
+	for _, aircraftType := range aircraftTypes {
+		if aircraft.Match(aircraftType) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *InternalService) sortFlight(flights []Flight, sortKeys []SortKey) []Flight {
+	sort.SliceStable(flights, func(i, j int) bool {
+		for _, key := range sortKeys {
+			compared := s.compareFlight(flights[i], flights[j], key.Field)
+			if compared == 0 {
+				continue
+			}
+			if key.Direction == SortDescending {
+				return compared > 0
+			}
+			return compared < 0
+		}
+		return flights[i].ID < flights[j].ID
+	})
+	return flights
+}
+
+func (s *InternalService) compareFlight(a, b Flight, field SortField) int {
+	switch field {
+	case SortFieldPrice:
+		return cmp.Compare(a.Price.AmountInIDR(), b.Price.AmountInIDR())
+	case SortFieldDuration:
+		return cmp.Compare(a.Duration.TotalMinute, b.Duration.TotalMinute)
+	case SortFieldDeparture:
+		return cmp.Compare(a.Departure.Timestamp, b.Departure.Timestamp)
+	case SortFieldArrival:
+		return cmp.Compare(a.Arrival.Timestamp, b.Arrival.Timestamp)
+	case SortFieldStops:
+		return cmp.Compare(a.Stops, b.Stops)
+	case SortFieldLayover:
+		return cmp.Compare(a.Layover, b.Layover)
+	case SortFieldBestValue:
+		return cmp.Compare(s.bestValue(a), s.bestValue(b))
+	}
+	return 0
+}
+
+func (s *InternalService) scoreFlight(flights []Flight, profile ScoringProfile) []Flight {
+	for i := range flights {
+		score := s.Scorer.Score(flights[i], profile)
+		flights[i].Score = &score
 	}
 	return flights
 }
 
-func CalculateBestValue(flight Flight) int {
-	multiplierClass := 1
-	if flight.CabinClass == BusinessClass {
-		multiplierClass = 2 // Infer only 2 class cabin
+func (s *InternalService) bestValue(flight Flight) float64 {
+	if flight.Score != nil {
+		return flight.Score.Total
+	}
+	return s.Scorer.ScoreWithDefaultProfile(flight).Total
+}
+
+// GetFlightDetail read flight from cache, when it is not cached the owning provider is queried using the given route
+func (s *InternalService) GetFlightDetail(ctx context.Context, flightID string, params GetFlightDetailParams) (Flight, error) {
+	if !allowedProvider(ctx, ProviderOfFlightID(flightID)) {
+		return Flight{}, ErrFlightNotFound
 	}
-	return flight.Price.AmountInIDR() + int(float32(flight.Duration.TotalMinute*10000)/float32(multiplierClass))
+	flight, err := s.CacheService.GetFlightByID(ctx, flightID)
+	if err != nil && !errors.Is(err, ErrFlightNotFound) {
+		s.cacheFailed(flightID, err)
+		err = ErrFlightNotFound // provider answer instead of the unavailable cache
+	}
+	if err != nil && !params.CanQueryProvider() {
+		return flight, err
+	}
+	if err == nil {
+		return tenantCurrency(ctx, []Flight{s.priceFlight(ctx, flight)})[0], nil
+	}
+
+	cabinClass := params.CabinClass
+	if cabinClass == "" {
+		cabinClass = string(EconomyClass)
+	}
+	flight, err = s.FetcherService.GetFlight(ProviderOfFlightID(flightID), GetFlightsParams{
+		Origin:        params.Origin,
+		Destination:   params.Destination,
+		DepartureDate: params.DepartureDate,
+		CabinClass:    cabinClass,
+		CabinOrBetter: true,
+	}, ProviderFlightID(flightID))
+	if err != nil {
+		return Flight{}, err
+	}
+	flight = withFlightID(flight, flightID) // keep the IDs of the search the flight come from
+
+	s.updateCachedFlight(ctx, flight)
+	return tenantCurrency(ctx, []Flight{s.priceFlight(ctx, flight)})[0], nil
+}
+
+func (s *InternalService) GetTravellerProfile(ctx context.Context, profileID string) (TravellerProfile, error) {
+	return s.CacheService.GetTravellerProfile(ctx, tenantID(ctx), profileID)
+}
+
+func (s *InternalService) SetTravellerProfile(ctx context.Context, profile TravellerProfile) error {
+	profile.TenantID = tenantID(ctx)
+	return s.CacheService.SetTravellerProfile(ctx, profile)
 }
@@ -0,0 +1,230 @@
+package internal
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"kevinjuniawan/bookcabin/config"
+)
+
+func flightIDs(flights []Flight) []string {
+	ids := make([]string, len(flights))
+	for i, flight := range flights {
+		ids[i] = flight.ID
+	}
+	return ids
+}
+
+func intPtr(value int) *int {
+	return &value
+}
+
+func int8Ptr(value int8) *int8 {
+	return &value
+}
+
+func int16Ptr(value int16) *int16 {
+	return &value
+}
+
+func TestFilterFlight(t *testing.T) {
+	departure := time.Date(2025, 12, 15, 6, 0, 0, 0, time.UTC)
+	flight := func(id string, price int, departAfter time.Duration, duration int16, layovers ...Layover) Flight {
+		departAt := departure.Add(departAfter)
+		return Flight{
+			ID:        id,
+			Price:     Price{Amount: price, Currency: "IDR"},
+			Departure: Airport{Timestamp: departAt.Unix()},
+			Arrival:   Airport{Timestamp: departAt.Add(time.Duration(duration) * time.Minute).Unix()},
+			Duration:  Duration{TotalMinute: duration},
+			Stops:     int8(len(layovers)),
+			Layovers:  layovers,
+		}
+	}
+	flights := []Flight{
+		flight("QZ7250_AirAsia", 650000, 0, 110),
+		flight("JT740_LionAir", 950000, 3*time.Hour, 180, Layover{Airport: "SUB", TotalMinute: 60}),
+		flight("GA400_GarudaIndonesia", 1250000, 6*time.Hour, 110),
+		flight("ID6514_BatikAir", 1500000, 12*time.Hour, 300, Layover{Airport: "UPG", TotalMinute: 150}),
+	}
+	tests := []struct {
+		name   string
+		params FilterFlightParams
+		want   []string
+	}{
+		{name: "no filter", want: []string{"QZ7250_AirAsia", "JT740_LionAir", "GA400_GarudaIndonesia", "ID6514_BatikAir"}},
+		{
+			name:   "price range is inclusive",
+			params: FilterFlightParams{Price: &FilterFlightPriceParams{LowestPrice: 650000, HighestPrice: 1250000}},
+			want:   []string{"QZ7250_AirAsia", "JT740_LionAir", "GA400_GarudaIndonesia"},
+		},
+		{
+			name:   "price range outside every flight",
+			params: FilterFlightParams{Price: &FilterFlightPriceParams{LowestPrice: 100000, HighestPrice: 200000}},
+			want:   []string{},
+		},
+		{
+			name:   "departure time range",
+			params: FilterFlightParams{TimeRange: &FilterFlightTimeParams{Type: FilterFlightTimeTypeDeparture, From: "2025-12-15T08:00:00Z", To: "2025-12-15T12:00:00Z"}},
+			want:   []string{"JT740_LionAir", "GA400_GarudaIndonesia"},
+		},
+		{
+			name:   "arrival time range",
+			params: FilterFlightParams{TimeRange: &FilterFlightTimeParams{Type: FilterFlightTimeTypeArrival, From: "2025-12-15T07:00:00Z", To: "2025-12-15T13:50:00Z"}},
+			want:   []string{"QZ7250_AirAsia", "JT740_LionAir", "GA400_GarudaIndonesia"},
+		},
+		{
+			name:   "price and time range combined",
+			params: FilterFlightParams{Price: &FilterFlightPriceParams{LowestPrice: 0, HighestPrice: 1000000}, TimeRange: &FilterFlightTimeParams{Type: FilterFlightTimeTypeDeparture, From: "2025-12-15T05:00:00Z", To: "2025-12-15T10:00:00Z"}},
+			want:   []string{"QZ7250_AirAsia", "JT740_LionAir"},
+		},
+		{
+			name:   "max duration",
+			params: FilterFlightParams{MaxDuration: int16Ptr(120)},
+			want:   []string{"QZ7250_AirAsia", "GA400_GarudaIndonesia"},
+		},
+		{
+			name:   "max stops",
+			params: FilterFlightParams{MaxStops: int8Ptr(0)},
+			want:   []string{"QZ7250_AirAsia", "GA400_GarudaIndonesia"},
+		},
+		{
+			name:   "layover range keep direct flights",
+			params: FilterFlightParams{Layover: &FilterFlightLayoverParams{MaxMinute: intPtr(90)}},
+			want:   []string{"QZ7250_AirAsia", "JT740_LionAir", "GA400_GarudaIndonesia"},
+		},
+		{
+			name:   "exclude connection airport",
+			params: FilterFlightParams{ConnectionAirports: &FilterFlightConnectionParams{Exclude: []string{"SUB"}}},
+			want:   []string{"QZ7250_AirAsia", "GA400_GarudaIndonesia", "ID6514_BatikAir"},
+		},
+		{
+			name:   "include connection airport and max duration",
+			params: FilterFlightParams{ConnectionAirports: &FilterFlightConnectionParams{Include: []string{"UPG"}}, MaxDuration: int16Ptr(200)},
+			want:   []string{"QZ7250_AirAsia", "GA400_GarudaIndonesia"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := flightIDs(FilterFlight(flights, tt.params)); !slices.Equal(got, tt.want) {
+				t.Errorf("FilterFlight() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func sortingFlight(id string, price int, duration int16, stops int8) Flight {
+	return Flight{ID: id, Price: Price{Amount: price, Currency: "IDR"}, Duration: Duration{TotalMinute: duration}, Stops: stops}
+}
+
+func TestSortFlight(t *testing.T) {
+	flights := []Flight{
+		sortingFlight("QZ7250_AirAsia", 500000, 120, 1),
+		sortingFlight("JT740_LionAir", 500000, 100, 0),
+		sortingFlight("GA400_GarudaIndonesia", 900000, 100, 0),
+		sortingFlight("ID6514_BatikAir", 500000, 100, 0),
+		sortingFlight("QZ532_AirAsia", 700000, 90, 0),
+	}
+	tests := []struct {
+		name string
+		keys []SortKey
+		want []string
+	}{
+		{
+			name: "ties are ordered by id",
+			keys: []SortKey{{Field: SortFieldPrice, Direction: SortAscending}},
+			want: []string{"ID6514_BatikAir", "JT740_LionAir", "QZ7250_AirAsia", "QZ532_AirAsia", "GA400_GarudaIndonesia"},
+		},
+		{
+			name: "ties stay ascending by id when descending",
+			keys: []SortKey{{Field: SortFieldPrice, Direction: SortDescending}},
+			want: []string{"GA400_GarudaIndonesia", "QZ532_AirAsia", "ID6514_BatikAir", "JT740_LionAir", "QZ7250_AirAsia"},
+		},
+		{
+			name: "second key break the tie before id",
+			keys: []SortKey{{Field: SortFieldPrice, Direction: SortAscending}, {Field: SortFieldDuration, Direction: SortAscending}},
+			want: []string{"ID6514_BatikAir", "JT740_LionAir", "QZ7250_AirAsia", "QZ532_AirAsia", "GA400_GarudaIndonesia"},
+		},
+		{
+			name: "stops then duration descending",
+			keys: []SortKey{{Field: SortFieldStops, Direction: SortAscending}, {Field: SortFieldDuration, Direction: SortDescending}},
+			want: []string{"GA400_GarudaIndonesia", "ID6514_BatikAir", "JT740_LionAir", "QZ532_AirAsia", "QZ7250_AirAsia"},
+		},
+	}
+	s := &InternalService{Scorer: NewScorer(config.Config{})}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// every input order give the same result
+			reversed := slices.Clone(flights)
+			slices.Reverse(reversed)
+			for _, input := range [][]Flight{slices.Clone(flights), reversed} {
+				if got := flightIDs(s.sortFlight(input, tt.keys)); !slices.Equal(got, tt.want) {
+					t.Errorf("sortFlight() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func cabinFare(bookingClass string, cabin Class, price int) Fare {
+	return Fare{ID: "GA410_GarudaIndonesia_" + bookingClass, BookingClass: bookingClass, CabinClass: cabin, Price: Price{Amount: price, Currency: "IDR"}, AvailableSeats: int16(price / 100000)}
+}
+
+func TestFilterFlightByCabin(t *testing.T) {
+	flights := []Flight{
+		{
+			ID:         "GA410_GarudaIndonesia",
+			CabinClass: EconomyClass,
+			Price:      Price{Amount: 1450000, Currency: "IDR"},
+			Fares: []Fare{
+				cabinFare("K", EconomyClass, 1450000),
+				cabinFare("W", PremiumEconomyClass, 2500000),
+				cabinFare("C", BusinessClass, 4800000),
+				cabinFare("I", BusinessClass, 3900000),
+			},
+		},
+		{ID: "JT740_LionAir", CabinClass: EconomyClass, Price: Price{Amount: 950000, Currency: "IDR"}},
+		{ID: "ID6514_BatikAir", CabinClass: BusinessClass, Price: Price{Amount: 3500000, Currency: "IDR"}},
+	}
+	tests := []struct {
+		name      string
+		cabin     Class
+		orBetter  bool
+		wantIDs   []string
+		wantPrice int      // main price of GA410
+		wantFares []string // fares of GA410
+	}{
+		{name: "economy only", cabin: EconomyClass, wantIDs: []string{"GA410_GarudaIndonesia", "JT740_LionAir"}, wantPrice: 1450000, wantFares: []string{"K"}},
+		{name: "economy or better", cabin: EconomyClass, orBetter: true, wantIDs: []string{"GA410_GarudaIndonesia", "JT740_LionAir", "ID6514_BatikAir"}, wantPrice: 1450000, wantFares: []string{"K", "W", "C", "I"}},
+		{name: "business use the cheapest business fare", cabin: BusinessClass, wantIDs: []string{"GA410_GarudaIndonesia", "ID6514_BatikAir"}, wantPrice: 3900000, wantFares: []string{"C", "I"}},
+		{name: "premium economy or better", cabin: PremiumEconomyClass, orBetter: true, wantIDs: []string{"GA410_GarudaIndonesia", "ID6514_BatikAir"}, wantPrice: 2500000, wantFares: []string{"W", "C", "I"}},
+		{name: "first", cabin: FirstClass, wantIDs: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filtered := FilterFlightByCabin(flights, tt.cabin, tt.orBetter)
+			if got := flightIDs(filtered); !slices.Equal(got, tt.wantIDs) {
+				t.Fatalf("FilterFlightByCabin() = %v, want %v", got, tt.wantIDs)
+			}
+			for _, flight := range filtered {
+				if flight.ID != "GA410_GarudaIndonesia" {
+					continue
+				}
+				fares := make([]string, len(flight.Fares))
+				for i, fare := range flight.Fares {
+					fares[i] = fare.BookingClass
+				}
+				if flight.Price.Amount != tt.wantPrice || !slices.Equal(fares, tt.wantFares) {
+					t.Errorf("GA410 price = %d fares %v, want %d fares %v", flight.Price.Amount, fares, tt.wantPrice, tt.wantFares)
+				}
+				if !flight.CabinClass.Accept(tt.cabin, tt.orBetter) {
+					t.Errorf("GA410 cabin = %s, not accepted for %s", flight.CabinClass, tt.cabin)
+				}
+			}
+		})
+	}
+	if len(flights[0].Fares) != 4 {
+		t.Errorf("input fares must not be changed")
+	}
+}
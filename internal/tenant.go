@@ -0,0 +1,289 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"kevinjuniawan/bookcabin/config"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidAPIKey         = errors.New("api key is invalid")
+	ErrAPIKeyNotFound        = errors.New("api key is not found")
+	ErrTenantNotFound        = errors.New("tenant is not found")
+	ErrTenantManagedByConfig = errors.New("tenant is managed by TENANTS config")
+	ErrAuthUnavailable       = errors.New("api key can not be verified while the cache is unavailable")
+)
+
+type Tenant struct {
+	ID               string   `json:"id"`
+	Name             string   `json:"name"`
+	RateLimitTier    string   `json:"rate_limit_tier"`          // RATE_LIMIT_TIERS name, empty use the default tier
+	AllowedProviders []string `json:"allowed_providers"`        // provider name, e.g. GarudaIndonesia, empty allow every provider
+	DefaultCurrency  string   `json:"default_currency"`         // IDR or USD, empty keep the provider currency
+	MarkupProfile    string   `json:"markup_profile"`           // price rule profile applied to the tenant
+	APIKeyHashes     []string `json:"api_key_hashes,omitempty"` // only for TENANTS config, SHA-256 hex of the keys
+}
+
+func (t Tenant) Validate() error {
+	if t.ID == "" {
+		return errors.New("tenant id must be filled")
+	}
+	if t.DefaultCurrency != "" && t.DefaultCurrency != "IDR" && t.DefaultCurrency != "USD" {
+		return errors.New("default currency must be IDR or USD")
+	}
+	if len(t.APIKeyHashes) != 0 {
+		return errors.New("api key must be issued from the admin api")
+	}
+	return nil
+}
+
+func (t Tenant) AllowProvider(provider string) bool {
+	return len(t.AllowedProviders) == 0 || slices.Contains(t.AllowedProviders, provider)
+}
+
+// APIKey is stored by the SHA-256 hash of the key, the key itself is only returned once when issued
+type APIKey struct {
+	ID        string    `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Hash      string    `json:"-"`
+}
+
+type IssuedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+func randomHex(size int) (string, error) {
+	bytes := make([]byte, size)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+type resolvedKey struct {
+	tenant    Tenant
+	expiredAt time.Time
+}
+
+// resolvedKeys remember the tenant of issued keys verified in the cache, used only while the cache is unavailable
+// so a key revoked during an outage is still accepted for at most API_KEY_CACHE_TTL
+type resolvedKeys struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	keys map[string]resolvedKey
+}
+
+func newResolvedKeys(ttl time.Duration) *resolvedKeys {
+	return &resolvedKeys{ttl: ttl, keys: map[string]resolvedKey{}}
+}
+
+func (r *resolvedKeys) get(hash string) (Tenant, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key, exist := r.keys[hash]
+	if !exist || time.Now().After(key.expiredAt) {
+		delete(r.keys, hash)
+		return Tenant{}, false
+	}
+	return key.tenant, true
+}
+
+func (r *resolvedKeys) set(hash string, tenant Tenant) {
+	if r.ttl <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys[hash] = resolvedKey{tenant: tenant, expiredAt: time.Now().Add(r.ttl)}
+}
+
+func (r *resolvedKeys) remove(hash string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.keys, hash)
+}
+
+type tenantContextKey struct{}
+
+func WithTenant(ctx context.Context, tenant Tenant) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+func TenantFromContext(ctx context.Context) (Tenant, bool) {
+	tenant, ok := ctx.Value(tenantContextKey{}).(Tenant)
+	return tenant, ok
+}
+
+// tenantID is empty without tenant, i.e. when authentication is disabled
+func tenantID(ctx context.Context) string {
+	tenant, _ := TenantFromContext(ctx)
+	return tenant.ID
+}
+
+// allowedProvider is true without tenant, i.e. when authentication is disabled
+func allowedProvider(ctx context.Context, provider string) bool {
+	tenant, ok := TenantFromContext(ctx)
+	return !ok || tenant.AllowProvider(provider)
+}
+
+// tenantFlights hide flights of providers the tenant is not allowed to sell
+func tenantFlights(ctx context.Context, flights []Flight) []Flight {
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return flights
+	}
+	allowed := []Flight{}
+	for _, flight := range flights {
+		if tenant.AllowProvider(flight.Provider) {
+			allowed = append(allowed, flight)
+		}
+	}
+	return allowed
+}
+
+// tenantCurrency convert prices to the tenant currency, done last so price filter and sorting stay in IDR
+func tenantCurrency(ctx context.Context, flights []Flight) []Flight {
+	tenant, ok := TenantFromContext(ctx)
+	if !ok || tenant.DefaultCurrency == "" {
+		return flights
+	}
+	for i := range flights {
+		flights[i] = flights[i].InCurrency(tenant.DefaultCurrency)
+	}
+	return flights
+}
+
+// NewTenants load TENANTS config, returning tenants by ID and tenant ID by API key hash
+func NewTenants(cfg config.Config) (map[string]Tenant, map[string]string) {
+	tenants := map[string]Tenant{}
+	keys := map[string]string{}
+	if cfg.Tenants == "" {
+		return tenants, keys
+	}
+	configTenants := map[string]Tenant{}
+	if err := json.Unmarshal([]byte(cfg.Tenants), &configTenants); err != nil {
+		log.Printf("fail to parse tenants config, Err : %v\n", err)
+	}
+	for id, tenant := range configTenants {
+		tenant.ID = id
+		hashes := tenant.APIKeyHashes
+		tenant.APIKeyHashes = nil
+		if err := tenant.Validate(); err != nil {
+			log.Printf("[%s] invalid tenant config, Err : %v\n", id, err)
+			continue
+		}
+		tenants[id] = tenant
+		for _, hash := range hashes {
+			keys[hash] = id
+		}
+	}
+	return tenants, keys
+}
+
+// Authenticate resolve the tenant of an API key, keys of TENANTS config are checked before the issued keys
+// while the cache is bypassed or failing, issued keys resolved in the last API_KEY_CACHE_TTL are still accepted
+func (s *InternalService) Authenticate(ctx context.Context, apiKey string) (Tenant, error) {
+	if apiKey == "" {
+		return Tenant{}, ErrInvalidAPIKey
+	}
+	hash := HashAPIKey(apiKey)
+	if tenantID, exist := s.tenantKeys[hash]; exist {
+		return s.tenants[tenantID], nil
+	}
+	if s.cacheBreaker.isOpen() {
+		return s.resolvedTenant(hash)
+	}
+
+	key, err := s.CacheService.GetAPIKey(ctx, hash)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		s.resolvedKeys.remove(hash)
+		return Tenant{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		s.cacheFailed("auth", err)
+		return s.resolvedTenant(hash)
+	}
+	tenant, err := s.GetTenant(ctx, key.TenantID)
+	if errors.Is(err, ErrTenantNotFound) {
+		s.resolvedKeys.remove(hash)
+		return Tenant{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		s.cacheFailed("auth", err)
+		return s.resolvedTenant(hash)
+	}
+	s.resolvedKeys.set(hash, tenant)
+	return tenant, nil
+}
+
+func (s *InternalService) resolvedTenant(hash string) (Tenant, error) {
+	if tenant, exist := s.resolvedKeys.get(hash); exist {
+		return tenant, nil
+	}
+	return Tenant{}, ErrAuthUnavailable
+}
+
+// IsAdminKey is false when ADMIN_API_KEY_HASH is not set, so the admin api is disabled
+func (s *InternalService) IsAdminKey(apiKey string) bool {
+	if s.Cfg.AdminAPIKeyHash == "" || apiKey == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(HashAPIKey(apiKey)), []byte(s.Cfg.AdminAPIKeyHash)) == 1
+}
+
+func (s *InternalService) GetTenant(ctx context.Context, tenantID string) (Tenant, error) {
+	if tenant, exist := s.tenants[tenantID]; exist {
+		return tenant, nil
+	}
+	return s.CacheService.GetTenant(ctx, tenantID)
+}
+
+func (s *InternalService) SetTenant(ctx context.Context, tenant Tenant) error {
+	if _, exist := s.tenants[tenant.ID]; exist {
+		return ErrTenantManagedByConfig
+	}
+	return s.CacheService.SetTenant(ctx, tenant)
+}
+
+func (s *InternalService) IssueAPIKey(ctx context.Context, tenantID string) (IssuedAPIKey, error) {
+	if _, err := s.GetTenant(ctx, tenantID); err != nil {
+		return IssuedAPIKey{}, err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return IssuedAPIKey{}, err
+	}
+	keyID, err := randomHex(8)
+	if err != nil {
+		return IssuedAPIKey{}, err
+	}
+	key := "bc_" + secret
+	issued := IssuedAPIKey{
+		APIKey: APIKey{ID: "key_" + keyID, TenantID: tenantID, CreatedAt: time.Now(), Hash: HashAPIKey(key)},
+		Key:    key,
+	}
+	if err := s.CacheService.SaveAPIKey(ctx, issued.APIKey); err != nil {
+		return IssuedAPIKey{}, err
+	}
+	return issued, nil
+}
+
+func (s *InternalService) RevokeAPIKey(ctx context.Context, keyID string) error {
+	return s.CacheService.RevokeAPIKey(ctx, keyID)
+}
@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kevinjuniawan/bookcabin/config"
+)
+
+// authCache serve issued keys until it is marked down
+type authCache struct {
+	ICache
+	down    bool
+	keys    map[string]APIKey
+	tenants map[string]Tenant
+}
+
+func (c *authCache) GetAPIKey(ctx context.Context, hash string) (APIKey, error) {
+	if c.down {
+		return APIKey{}, errors.New("connection refused")
+	}
+	key, exist := c.keys[hash]
+	if !exist {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+func (c *authCache) GetTenant(ctx context.Context, tenantID string) (Tenant, error) {
+	if c.down {
+		return Tenant{}, errors.New("connection refused")
+	}
+	tenant, exist := c.tenants[tenantID]
+	if !exist {
+		return Tenant{}, ErrTenantNotFound
+	}
+	return tenant, nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiKey     string
+		verified   bool // key was verified once before the cache is down
+		cacheDown  bool
+		bypassed   bool
+		wantTenant string
+		wantErr    error
+	}{
+		{name: "config key", apiKey: "config-key", wantTenant: "acme"},
+		{name: "config key while cache is down", apiKey: "config-key", cacheDown: true, wantTenant: "acme"},
+		{name: "issued key", apiKey: "issued-key", wantTenant: "globex"},
+		{name: "unknown key", apiKey: "unknown-key", wantErr: ErrInvalidAPIKey},
+		{name: "empty key", apiKey: "", wantErr: ErrInvalidAPIKey},
+		{name: "issued key verified before the cache is down", apiKey: "issued-key", verified: true, cacheDown: true, wantTenant: "globex"},
+		{name: "issued key verified before the cache is bypassed", apiKey: "issued-key", verified: true, bypassed: true, wantTenant: "globex"},
+		{name: "issued key not verified while the cache is down", apiKey: "issued-key", cacheDown: true, wantErr: ErrAuthUnavailable},
+		{name: "unknown key while the cache is down", apiKey: "unknown-key", cacheDown: true, wantErr: ErrAuthUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := &authCache{
+				keys:    map[string]APIKey{HashAPIKey("issued-key"): {ID: "key_1", TenantID: "globex"}},
+				tenants: map[string]Tenant{"globex": {ID: "globex"}},
+			}
+			s := NewInternalService(InternalServiceParams{
+				CacheService: cache,
+				Cfg: config.Config{
+					Tenants:         `{"acme": {"api_key_hashes": ["` + HashAPIKey("config-key") + `"]}}`,
+					APIKeyCacheTTL:  time.Minute,
+					CacheBypassTime: time.Minute,
+				},
+			})
+			if tt.verified {
+				if _, err := s.Authenticate(context.Background(), tt.apiKey); err != nil {
+					t.Fatalf("Authenticate() before outage, Err : %v", err)
+				}
+			}
+			cache.down = tt.cacheDown
+			if tt.bypassed {
+				s.cacheBreaker.trip()
+			}
+
+			tenant, err := s.Authenticate(context.Background(), tt.apiKey)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate() err = %v, want %v", err, tt.wantErr)
+			}
+			if tenant.ID != tt.wantTenant {
+				t.Errorf("Authenticate() tenant = %s, want %s", tenant.ID, tt.wantTenant)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidWarmingRoute = errors.New("warming route is invalid")
+	ErrWarmingRunNotFound  = errors.New("warming run is not found")
+)
+
+// WarmingRoute is parsed from ORIGIN-DESTINATION:DAYS, e.g. CGK-DPS:30 warm the next 30 departure dates from today
+type WarmingRoute struct {
+	Origin      string
+	Destination string
+	HorizonDays int
+}
+
+func ParseWarmingRoute(route string) (WarmingRoute, error) {
+	airports, days, found := strings.Cut(strings.TrimSpace(route), ":")
+	if !found {
+		return WarmingRoute{}, fmt.Errorf("%s : %w", route, ErrInvalidWarmingRoute)
+	}
+	origin, destination, found := strings.Cut(airports, "-")
+	horizonDays, err := strconv.Atoi(days)
+	if !found || origin == "" || destination == "" || err != nil || horizonDays <= 0 {
+		return WarmingRoute{}, fmt.Errorf("%s : %w", route, ErrInvalidWarmingRoute)
+	}
+	return WarmingRoute{Origin: strings.ToUpper(origin), Destination: strings.ToUpper(destination), HorizonDays: horizonDays}, nil
+}
+
+type warmingStatus int
+
+const (
+	warmingWarmed warmingStatus = iota
+	warmingSkippedFresh
+	warmingSkippedLocked
+)
+
+type WarmingRun struct {
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at"`
+	DurationMs    int64     `json:"duration_ms"`
+	Searches      int       `json:"searches"`       // route and date combination of the run
+	Warmed        int       `json:"warmed"`         // fetched from providers and cached
+	SkippedFresh  int       `json:"skipped_fresh"`  // cache was still fresh
+	SkippedLocked int       `json:"skipped_locked"` // route was being rebuilt by a search or another replica
+	Failed        int       `json:"failed"`         // provider or cache failure
+	FlightsCached int       `json:"flights_cached"` // total flights written
+}
+
+// StartCacheWarming run the warming right away then every WARMING_INTERVAL until ctx is done
+func (s *InternalService) StartCacheWarming(ctx context.Context) {
+	ticker := time.NewTicker(s.Cfg.WarmingInterval)
+	defer ticker.Stop()
+	for {
+		run := s.WarmCache(ctx)
+		log.Printf("cache warming done in %dms, %d searches, %d warmed, %d fresh, %d locked, %d failed\n", run.DurationMs, run.Searches, run.Warmed, run.SkippedFresh, run.SkippedLocked, run.Failed)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// WarmCache fetch every configured route and date whose cache is not fresh, with at most WARMING_CONCURRENCY fetches at once
+func (s *InternalService) WarmCache(ctx context.Context) WarmingRun {
+	run := WarmingRun{StartedAt: time.Now()}
+	searches := s.warmingSearches(ctx)
+	run.Searches = len(searches)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, max(s.Cfg.WarmingConcurrency, 1))
+	for _, params := range searches {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(params GetFlightsParams) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			status, flightCount, err := s.warmSearch(ctx, params)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil:
+				log.Printf("[%s]fail to warm cache, Err : %v\n", params.RouteKey(), err)
+				run.Failed++
+			case status == warmingSkippedFresh:
+				run.SkippedFresh++
+			case status == warmingSkippedLocked:
+				run.SkippedLocked++
+			default:
+				run.Warmed++
+				run.FlightsCached += flightCount
+			}
+		}(params)
+	}
+	wg.Wait()
+
+	run.FinishedAt = time.Now()
+	run.DurationMs = run.FinishedAt.Sub(run.StartedAt).Milliseconds()
+	if err := s.CacheService.SaveWarmingRun(ctx, run); err != nil {
+		log.Printf("fail to save warming run, Err : %v\n", err)
+	}
+	return run
+}
+
+// warmSearch rebuild the route under the rebuild lock like a search cache miss, a route already being rebuilt is skipped
+func (s *InternalService) warmSearch(ctx context.Context, params GetFlightsParams) (status warmingStatus, flightCount int, err error) {
+	isFresh, err := s.CacheService.IsFresh(ctx, params)
+	if err != nil {
+		return warmingWarmed, 0, err
+	}
+	if isFresh {
+		return warmingSkippedFresh, 0, nil
+	}
+	acquired, err := s.CacheService.AcquireRebuildLock(ctx, params)
+	if err != nil {
+		return warmingWarmed, 0, err
+	}
+	if !acquired {
+		return warmingSkippedLocked, 0, nil
+	}
+	// a live search of the same route is joined instead of fetched twice, only the provider result is shared
+	// so a failing cache write is not returned to the joined search
+	flightsData, coalesced, err := s.inflight.do(params.RouteKey(), func() (FlightDataResponse, error) {
+		flightsData, err := s.FetcherService.GetFlights(params)
+		if err != nil {
+			return flightsData, err
+		}
+		flightsData.Flights = scopeFlights(flightsData.Flights, params)
+		return flightsData, nil
+	})
+	// the lock is given back when nothing is written here, a joined fetch is written by its owner
+	if err != nil || coalesced {
+		s.releaseRebuildLock(params)
+	}
+	if err != nil {
+		return warmingWarmed, 0, err
+	}
+	if !coalesced {
+		if err := s.CacheService.SetFlights(ctx, flightsData.Flights, params, time.Duration(0)); err != nil {
+			return warmingWarmed, 0, err
+		}
+	}
+	return warmingWarmed, len(flightsData.Flights), nil
+}
+
+// warmingSearches combine WARMING_ROUTES with the routes kept in the cache, every route is searched for each day
+// of its horizon and each WARMING_CABIN_CLASSES
+func (s *InternalService) warmingSearches(ctx context.Context) []GetFlightsParams {
+	routes := strings.Split(s.Cfg.WarmingRoutes, ",")
+	cachedRoutes, err := s.CacheService.GetWarmingRoutes(ctx)
+	if err != nil {
+		log.Printf("fail to get warming routes from cache, Err : %v\n", err)
+	}
+	routes = append(routes, cachedRoutes...)
+
+	searches := []GetFlightsParams{}
+	seen := map[string]bool{}
+	today := time.Now()
+	for _, routeConfig := range routes {
+		if strings.TrimSpace(routeConfig) == "" {
+			continue
+		}
+		route, err := ParseWarmingRoute(routeConfig)
+		if err != nil {
+			log.Printf("skip warming route, Err : %v\n", err)
+			continue
+		}
+		for day := 0; day < route.HorizonDays; day++ {
+			for _, cabinClass := range strings.Split(s.Cfg.WarmingCabinClasses, ",") {
+				params := GetFlightsParams{
+					Origin:        route.Origin,
+					Destination:   route.Destination,
+					DepartureDate: today.AddDate(0, 0, day).Format("2006-01-02"),
+					Passenger:     1,
+					CabinClass:    strings.TrimSpace(cabinClass),
+				}
+				if !Class(params.CabinClass).IsValid() || seen[params.RouteKey()] {
+					continue
+				}
+				seen[params.RouteKey()] = true
+				searches = append(searches, params)
+			}
+		}
+	}
+	return searches
+}
+
+func (s *InternalService) GetLastWarmingRun(ctx context.Context) (WarmingRun, error) {
+	return s.CacheService.GetWarmingRun(ctx)
+}
@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kevinjuniawan/bookcabin/config"
+)
+
+// warmingCache record the rebuild lock and snapshot writes of a route
+type warmingCache struct {
+	ICache
+	fresh    bool
+	locked   bool
+	released int
+	written  int
+}
+
+func (c *warmingCache) IsFresh(ctx context.Context, params GetFlightsParams) (bool, error) {
+	return c.fresh, nil
+}
+
+func (c *warmingCache) AcquireRebuildLock(ctx context.Context, params GetFlightsParams) (bool, error) {
+	return !c.locked, nil
+}
+
+func (c *warmingCache) ReleaseRebuildLock(ctx context.Context, params GetFlightsParams) error {
+	c.released++
+	return nil
+}
+
+func (c *warmingCache) SetFlights(ctx context.Context, flights []Flight, params GetFlightsParams, ttl time.Duration) error {
+	c.written++
+	return nil
+}
+
+type warmingFetcher struct {
+	IFetcher
+	err     error
+	fetched int
+}
+
+func (f *warmingFetcher) GetFlights(params GetFlightsParams) (FlightDataResponse, error) {
+	f.fetched++
+	return FlightDataResponse{Flights: []Flight{{ID: "GA400_GarudaIndonesia"}}}, f.err
+}
+
+func TestWarmSearch(t *testing.T) {
+	tests := []struct {
+		name         string
+		fresh        bool
+		locked       bool
+		fetchErr     error
+		wantStatus   warmingStatus
+		wantErr      bool
+		wantFetched  int
+		wantWritten  int
+		wantReleased int
+	}{
+		{name: "warmed under the lock", wantStatus: warmingWarmed, wantFetched: 1, wantWritten: 1},
+		{name: "fresh route", fresh: true, wantStatus: warmingSkippedFresh},
+		{name: "route being rebuilt", locked: true, wantStatus: warmingSkippedLocked},
+		{name: "provider failure release the lock", fetchErr: errors.New("timeout"), wantErr: true, wantFetched: 1, wantReleased: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := &warmingCache{fresh: tt.fresh, locked: tt.locked}
+			fetcher := &warmingFetcher{err: tt.fetchErr}
+			s := NewInternalService(InternalServiceParams{CacheService: cache, FetcherService: fetcher, Cfg: config.Config{}})
+
+			params := GetFlightsParams{Origin: "CGK", Destination: "DPS", DepartureDate: "2025-12-15", Passenger: 1, CabinClass: "economy"}
+			status, _, err := s.warmSearch(context.Background(), params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("warmSearch() Err = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && status != tt.wantStatus {
+				t.Errorf("warmSearch() status = %d, want %d", status, tt.wantStatus)
+			}
+			if fetcher.fetched != tt.wantFetched || cache.written != tt.wantWritten || cache.released != tt.wantReleased {
+				t.Errorf("fetched %d, written %d, released %d, want %d, %d, %d",
+					fetcher.fetched, cache.written, cache.released, tt.wantFetched, tt.wantWritten, tt.wantReleased)
+			}
+		})
+	}
+}